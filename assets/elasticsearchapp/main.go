@@ -0,0 +1,180 @@
+/*
+   Copyright 2020 SUSE
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-community/go-cfenv"
+
+	"github.com/SUSE/minibroker-integration-tests/assets/internal/app"
+)
+
+func main() {
+	app.Main(&workload{})
+}
+
+// workload uses the Elasticsearch REST API directly, which needs no client library.
+type workload struct {
+	client  *http.Client
+	baseURL *url.URL
+	plan    string
+}
+
+func (w *workload) Connect(ctx context.Context, service *cfenv.Service) error {
+	uriStr, ok := service.Credentials["uri"].(string)
+	if !ok {
+		host, ok := service.Credentials["host"].(string)
+		if !ok {
+			return fmt.Errorf("neither URI nor host supplied")
+		}
+		uriStr = fmt.Sprintf("http://%s:%v", host, service.Credentials["port"])
+	}
+	baseURL, err := url.Parse(uriStr)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Connecting to %s://%s\n", baseURL.Scheme, baseURL.Host)
+	w.client = &http.Client{Timeout: 30 * time.Second}
+	w.baseURL = baseURL
+	w.plan = service.Plan
+	return nil
+}
+
+func (w *workload) Run(ctx context.Context) error {
+	return app.RunChecks(ctx, []app.Check{
+		{Name: "version", Run: w.checkVersion},
+		{Name: "cluster_health", Run: w.checkClusterHealth},
+		{Name: "index_search", Run: w.checkIndexSearch},
+	})
+}
+
+// do sends a request with the optional JSON body and decodes the JSON response into v, if not nil.
+func (w *workload) do(ctx context.Context, method string, path string, body interface{}, v interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		bodyBytes, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(bodyBytes)
+	}
+	endpoint := *w.baseURL
+	endpoint.Path = strings.TrimSuffix(endpoint.Path, "/") + path
+	req, err := http.NewRequestWithContext(ctx, method, endpoint.String(), reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		resBody, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("%s %s failed with status %q: %s", method, path, res.Status, resBody)
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// checkVersion asserts the server version matches the plan.
+func (w *workload) checkVersion(ctx context.Context) error {
+	var info struct {
+		Version struct {
+			Number string `json:"number"`
+		} `json:"version"`
+	}
+	if err := w.do(ctx, http.MethodGet, "/", nil, &info); err != nil {
+		return err
+	}
+	fmt.Printf("Server version %q\n", info.Version.Number)
+	return app.CheckPlanVersion(info.Version.Number, w.plan)
+}
+
+// checkClusterHealth asserts the cluster status isn't red.
+func (w *workload) checkClusterHealth(ctx context.Context) error {
+	var health struct {
+		Status string `json:"status"`
+	}
+	if err := w.do(ctx, http.MethodGet, "/_cluster/health", nil, &health); err != nil {
+		return err
+	}
+	fmt.Printf("Cluster status %q\n", health.Status)
+	if health.Status == "red" {
+		return fmt.Errorf("Cluster status is red")
+	}
+	return nil
+}
+
+// checkIndexSearch indexes a document in a new index, gets and searches it, then deletes the index.
+func (w *workload) checkIndexSearch(ctx context.Context) error {
+	value := strconv.FormatInt(time.Now().UnixNano(), 10)
+	index := "/mits-" + value
+	if err := w.do(ctx, http.MethodPut, index+"/_doc/1?refresh=true", map[string]string{"mits_id": value}, nil); err != nil {
+		return err
+	}
+	defer w.do(context.Background(), http.MethodDelete, index, nil, nil)
+
+	var doc struct {
+		Source struct {
+			MitsID string `json:"mits_id"`
+		} `json:"_source"`
+	}
+	if err := w.do(ctx, http.MethodGet, index+"/_doc/1", nil, &doc); err != nil {
+		return err
+	}
+	if doc.Source.MitsID != value {
+		return fmt.Errorf("Value %q is not the expected %q", doc.Source.MitsID, value)
+	}
+
+	var search struct {
+		Hits struct {
+			Hits []json.RawMessage `json:"hits"`
+		} `json:"hits"`
+	}
+	query := map[string]interface{}{
+		"query": map[string]interface{}{
+			"match": map[string]string{"mits_id": value},
+		},
+	}
+	if err := w.do(ctx, http.MethodPost, index+"/_search", query, &search); err != nil {
+		return err
+	}
+	if len(search.Hits.Hits) != 1 {
+		return fmt.Errorf("Search found %d documents, expected 1", len(search.Hits.Hits))
+	}
+	return nil
+}
+
+func (w *workload) Close() error {
+	w.client.CloseIdleConnections()
+	return nil
+}
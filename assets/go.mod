@@ -0,0 +1,14 @@
+module github.com/SUSE/minibroker-integration-tests/assets
+
+go 1.14
+
+require (
+	github.com/cloudfoundry-community/go-cfenv v1.18.0
+	github.com/go-redis/redis/v8 v8.0.0-beta.7
+	github.com/go-sql-driver/mysql v1.5.0
+	github.com/jackc/pgx/v4 v4.8.1
+	github.com/onsi/ginkgo v1.14.0
+	github.com/onsi/gomega v1.10.1
+	github.com/streadway/amqp v1.0.0
+	go.mongodb.org/mongo-driver v1.4.0
+)
@@ -0,0 +1,276 @@
+/*
+   Copyright 2020 SUSE
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package app holds the startup shared by all the MITS asset apps and defines the contract every
+// asset app follows, so MITS can test any service class with it:
+//
+// The app is configured from the environment. The bound service is looked up by SERVICE_NAME,
+// SERVICE_LABEL or SERVICE_TAG, else the only bound service is used. SERVICE_CREDENTIALS replaces
+// the binding with the given JSON credentials and SERVICE_TLS=true requires TLS. SEED_MODE and
+// SEED_VALUE seed or verify a value, for the apps implementing Seeder. PORT is the port to serve on.
+//
+// The app runs its workload once before serving. If the workload fails, the app exits with a
+// non-zero status before listening, so cf start fails.
+//
+// When CHECK_ONLY=true, e.g. in a CF task, the app exits with the status 0 once the workload
+// succeeds instead of serving.
+//
+// Once serving, GET /checks runs the workload again and serves a Report with the result of every
+// check, with the status 200 if they all passed and 500 otherwise. /workload serves the same and GET
+// / always serves "ok".
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-community/go-cfenv"
+)
+
+// Workload is implemented by each asset app to exercise its bound service.
+type Workload interface {
+	// Connect establishes the connection to the bound service.
+	Connect(ctx context.Context, service *cfenv.Service) error
+	// Run performs the assertions against the connected service.
+	Run(ctx context.Context) error
+	// Close releases the connection to the bound service.
+	Close() error
+}
+
+// Seeder is implemented by the asset apps that can persist a value across app instances, used to
+// assert that data survives Minibroker upgrades.
+type Seeder interface {
+	// Seed writes the value to the connected service.
+	Seed(ctx context.Context, value string) error
+	// Verify asserts that the value was previously seeded in the connected service.
+	Verify(ctx context.Context, value string) error
+}
+
+// Report is the body served by the /checks and /workload endpoints.
+type Report struct {
+	Error      string        `json:"error,omitempty"`
+	Duration   time.Duration `json:"duration"`
+	Reconnects int           `json:"reconnects"`
+	StartedAt  time.Time     `json:"started_at"`
+	// Checks are the results of the checks run by the last attempt, if the workload uses
+	// RunChecks.
+	Checks []CheckResult `json:"checks,omitempty"`
+}
+
+// Main looks up the bound service, runs the workload once and only then starts serving on PORT.
+// The app fails to start if the workload fails. Every request to /checks or /workload runs the
+// workload again, reconnecting to the service once if it fails.
+// When SEED_MODE is set to "write" or "read", the SEED_VALUE is also seeded or verified before
+// serving, and verified again on every request to /checks or /workload. Apps sharing a service
+// instance must be given distinct SEED_VALUEs. When CHECK_ONLY is set to "true", Main returns
+// once the workload succeeds instead of serving.
+func Main(workload Workload) {
+	service, err := lookupService()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if err := workload.Connect(ctx, service); err != nil {
+		log.Fatal(err)
+	}
+	defer workload.Close()
+
+	if err := workload.Run(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := seed(ctx, workload); err != nil {
+		log.Fatal(err)
+	}
+
+	if os.Getenv("CHECK_ONLY") == "true" {
+		log.Printf("All checks passed")
+		return
+	}
+
+	runner := &runner{
+		workload:  workload,
+		service:   service,
+		startedAt: time.Now(),
+	}
+	if seeder, ok := workload.(Seeder); ok && os.Getenv("SEED_MODE") != "" {
+		runner.seeder = seeder
+		runner.seedValue = os.Getenv("SEED_VALUE")
+	}
+
+	port, exists := os.LookupEnv("PORT")
+	if !exists {
+		port = "8080"
+	}
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "ok")
+	})
+	http.Handle("/checks", runner)
+	http.Handle("/workload", runner)
+	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%s", port), nil))
+}
+
+// lookupService returns the bound service named SERVICE_NAME, else the only one labeled
+// SERVICE_LABEL, else the only one tagged SERVICE_TAG, else the only bound service. When
+// SERVICE_CREDENTIALS is set to a JSON object, it is used as the credentials of an unbound service
+// instead, e.g. from a service key.
+func lookupService() (*cfenv.Service, error) {
+	serviceName := os.Getenv("SERVICE_NAME")
+
+	if credentials, ok := os.LookupEnv("SERVICE_CREDENTIALS"); ok {
+		service := &cfenv.Service{Name: serviceName}
+		if err := json.Unmarshal([]byte(credentials), &service.Credentials); err != nil {
+			return nil, fmt.Errorf("invalid SERVICE_CREDENTIALS: %w", err)
+		}
+		return service, nil
+	}
+
+	appEnv, err := cfenv.Current()
+	if err != nil {
+		return nil, err
+	}
+
+	if serviceName != "" {
+		log.Printf("Looking up the service named %q", serviceName)
+		return appEnv.Services.WithName(serviceName)
+	}
+	if label := os.Getenv("SERVICE_LABEL"); label != "" {
+		log.Printf("Looking up the service labeled %q", label)
+		services, err := appEnv.Services.WithLabel(label)
+		if err != nil {
+			return nil, err
+		}
+		return onlyService(services)
+	}
+	if tag := os.Getenv("SERVICE_TAG"); tag != "" {
+		log.Printf("Looking up the service tagged %q", tag)
+		services, err := appEnv.Services.WithTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		return onlyService(services)
+	}
+
+	log.Printf("Looking up the only bound service")
+	var services []cfenv.Service
+	for _, labeled := range appEnv.Services {
+		services = append(services, labeled...)
+	}
+	return onlyService(services)
+}
+
+func onlyService(services []cfenv.Service) (*cfenv.Service, error) {
+	if len(services) != 1 {
+		return nil, fmt.Errorf("expected exactly 1 matching service, found %d", len(services))
+	}
+	return &services[0], nil
+}
+
+func seed(ctx context.Context, workload Workload) error {
+	mode := os.Getenv("SEED_MODE")
+	if mode == "" {
+		return nil
+	}
+	seeder, ok := workload.(Seeder)
+	if !ok {
+		return fmt.Errorf("SEED_MODE set but the app does not support seeding")
+	}
+	value := os.Getenv("SEED_VALUE")
+	if value == "" {
+		return fmt.Errorf("SEED_VALUE not set")
+	}
+	switch mode {
+	case "write":
+		return seeder.Seed(ctx, value)
+	case "read":
+		return seeder.Verify(ctx, value)
+	default:
+		return fmt.Errorf("invalid SEED_MODE %q", mode)
+	}
+}
+
+// runner serializes the workload runs requested over HTTP.
+type runner struct {
+	mutex      sync.Mutex
+	workload   Workload
+	service    *cfenv.Service
+	seeder     Seeder
+	seedValue  string
+	reconnects int
+	startedAt  time.Time
+	results    []CheckResult
+}
+
+func (runner *runner) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	runner.mutex.Lock()
+	defer runner.mutex.Unlock()
+
+	start := time.Now()
+	err := runner.run(r.Context())
+	report := Report{
+		Duration:   time.Since(start),
+		Reconnects: runner.reconnects,
+		StartedAt:  runner.startedAt,
+		Checks:     runner.results,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		log.Printf("workload failed: %v", err)
+		report.Error = err.Error()
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+func (runner *runner) run(ctx context.Context) error {
+	err := runner.check(ctx)
+	if err == nil {
+		return nil
+	}
+	log.Printf("workload failed, reconnecting: %v", err)
+	runner.workload.Close()
+	runner.reconnects++
+	if err := runner.workload.Connect(ctx, runner.service); err != nil {
+		return fmt.Errorf("failed to reconnect: %w", err)
+	}
+	return runner.check(ctx)
+}
+
+// check runs the workload and verifies the seeded value, if any, recording the check results.
+func (runner *runner) check(ctx context.Context) error {
+	runner.results = nil
+	ctx = withResults(ctx, &runner.results)
+	if err := runner.workload.Run(ctx); err != nil {
+		return err
+	}
+	if runner.seeder == nil {
+		return nil
+	}
+	start := time.Now()
+	err := runner.seeder.Verify(ctx, runner.seedValue)
+	recordResult(ctx, "seed", start, err)
+	if err != nil {
+		return fmt.Errorf("failed to verify seeded value: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+/*
+   Copyright 2020 SUSE
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Check is a named assertion run by an asset app against its service.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// CheckResult is the outcome of a check, as served by the /checks endpoint.
+type CheckResult struct {
+	Name     string        `json:"name"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+type resultsKey struct{}
+
+// withResults returns a context in which RunChecks records the results of the checks.
+func withResults(ctx context.Context, results *[]CheckResult) context.Context {
+	return context.WithValue(ctx, resultsKey{}, results)
+}
+
+// recordResult records the result of a check if the context was set up by withResults.
+func recordResult(ctx context.Context, name string, start time.Time, err error) {
+	results, ok := ctx.Value(resultsKey{}).(*[]CheckResult)
+	if !ok {
+		return
+	}
+	result := CheckResult{Name: name, Duration: time.Since(start)}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	*results = append(*results, result)
+}
+
+// RunChecks runs all the checks, logging the result of each one, and returns an error naming every
+// failed check.
+func RunChecks(ctx context.Context, checks []Check) error {
+	var failures []string
+	for _, check := range checks {
+		start := time.Now()
+		err := check.Run(ctx)
+		recordResult(ctx, check.Name, start, err)
+		if err != nil {
+			log.Printf("check %s: failed: %v", check.Name, err)
+			failures = append(failures, fmt.Sprintf("%s: %v", check.Name, err))
+			continue
+		}
+		log.Printf("check %s: ok", check.Name)
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("%d of %d checks failed: %s", len(failures), len(checks), strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+var (
+	// planVersionPattern matches the plan names Minibroker derives from the chart app versions.
+	planVersionPattern = regexp.MustCompile(`^[0-9]+(-[0-9]+)*$`)
+	// serverVersionPattern matches the leading version number reported by a server.
+	serverVersionPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)*`)
+)
+
+// CheckPlanVersion asserts that the version reported by the server matches the plan it was
+// provisioned from, e.g. "10.3.22-MariaDB" for the plan "10-3-22". Only the components present in
+// both versions are compared, since some servers omit the patch version. Plans not named after a
+// version match any server version.
+func CheckPlanVersion(serverVersion string, plan string) error {
+	if !planVersionPattern.MatchString(plan) {
+		return nil
+	}
+	planComponents := strings.Split(plan, "-")
+	versionComponents := strings.Split(serverVersionPattern.FindString(serverVersion), ".")
+	for i := 0; i < len(planComponents) && i < len(versionComponents); i++ {
+		if planComponents[i] != versionComponents[i] {
+			return fmt.Errorf("server version %q doesn't match the plan %q", serverVersion, plan)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+/*
+   Copyright 2020 SUSE
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RunChecks", func() {
+	passing := func(ctx context.Context) error { return nil }
+	failing := func(ctx context.Context) error { return fmt.Errorf("boom") }
+
+	It("should succeed when every check passes", func() {
+		Expect(RunChecks(context.Background(), []Check{
+			{Name: "first", Run: passing},
+			{Name: "second", Run: passing},
+		})).To(Succeed())
+	})
+
+	It("should run every check and name the failed ones", func() {
+		var ran []string
+		run := func(name string, err error) func(context.Context) error {
+			return func(ctx context.Context) error {
+				ran = append(ran, name)
+				return err
+			}
+		}
+		err := RunChecks(context.Background(), []Check{
+			{Name: "first", Run: run("first", fmt.Errorf("boom"))},
+			{Name: "second", Run: run("second", nil)},
+			{Name: "third", Run: run("third", fmt.Errorf("bang"))},
+		})
+		Expect(ran).To(Equal([]string{"first", "second", "third"}))
+		Expect(err).To(MatchError("2 of 3 checks failed: first: boom; third: bang"))
+	})
+
+	It("should record the results when the context is set up for it", func() {
+		var results []CheckResult
+		ctx := withResults(context.Background(), &results)
+		Expect(RunChecks(ctx, []Check{
+			{Name: "first", Run: passing},
+			{Name: "second", Run: failing},
+		})).NotTo(Succeed())
+		Expect(results).To(HaveLen(2))
+		Expect(results[0].Name).To(Equal("first"))
+		Expect(results[0].Error).To(BeEmpty())
+		Expect(results[1].Name).To(Equal("second"))
+		Expect(results[1].Error).To(Equal("boom"))
+	})
+})
+
+var _ = Describe("CheckPlanVersion", func() {
+	DescribeTable("matching the server version against the plan",
+		func(serverVersion string, plan string, matches bool) {
+			err := CheckPlanVersion(serverVersion, plan)
+			if matches {
+				Expect(err).NotTo(HaveOccurred())
+			} else {
+				Expect(err).To(HaveOccurred())
+			}
+		},
+		Entry("an exact version", "5.7.30", "5-7-30", true),
+		Entry("a version with a suffix", "10.3.22-MariaDB", "10-3-22", true),
+		Entry("a version with a build suffix", "4.2.4+build", "4-2-4", true),
+		Entry("a server omitting the patch version", "11.7", "11-7-0", true),
+		Entry("a plan omitting the patch version", "3.8.2", "3-8", true),
+		Entry("a different major version", "8.0.21", "5-7-30", false),
+		Entry("a different patch version", "10.3.23-MariaDB", "10-3-22", false),
+		Entry("a version prefix that isn't a component", "10.30.1", "10-3", false),
+		Entry("a server version without a number", "unknown", "5-7-30", false),
+		Entry("an empty plan", "5.7.30", "", true),
+		Entry("a plan not named after a version", "5.7.30", "default", true),
+	)
+})
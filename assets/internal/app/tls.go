@@ -0,0 +1,127 @@
+/*
+   Copyright 2020 SUSE
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package app
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"time"
+)
+
+// handshakeTimeout bounds the TLS handshakes performed by HandshakeTLS.
+const handshakeTimeout = 10 * time.Second
+
+// caKeys are the credentials keys a CA certificate is looked up from, in PEM.
+var caKeys = []string{"ca", "ca_cert", "cacert", "tls_ca"}
+
+// TLS holds the TLS settings for a service, derived from its credentials.
+type TLS struct {
+	// Enabled is whether the connection to the service must use TLS.
+	Enabled bool
+	// Config is the TLS config to connect with. It only verifies the server certificate when a CA
+	// is provided in the credentials.
+	Config *tls.Config
+	// Verified is whether the server certificate is verified against a CA from the credentials.
+	Verified bool
+}
+
+// LoadTLS derives the TLS settings from the credentials. TLS is enabled when SERVICE_TLS is set to
+// "true", when the credentials set tls or ssl to true, or when the uri requires it, e.g. with the
+// rediss or amqps scheme, sslmode=require or tls=true.
+func LoadTLS(credentials map[string]interface{}, serverName string) (*TLS, error) {
+	settings := &TLS{
+		Enabled: os.Getenv("SERVICE_TLS") == "true" || credentials["tls"] == true || credentials["ssl"] == true,
+		Config:  &tls.Config{ServerName: serverName},
+	}
+	if uri, ok := credentials["uri"].(string); ok && uriRequiresTLS(uri) {
+		settings.Enabled = true
+	}
+
+	for _, key := range caKeys {
+		ca, ok := credentials[key].(string)
+		if !ok || ca == "" {
+			continue
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(ca)) {
+			return nil, fmt.Errorf("invalid CA certificate in %q", key)
+		}
+		settings.Config.RootCAs = pool
+		settings.Verified = true
+		break
+	}
+	settings.Config.InsecureSkipVerify = !settings.Verified
+	return settings, nil
+}
+
+func uriRequiresTLS(uri string) bool {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return false
+	}
+	switch parsed.Scheme {
+	case "rediss", "amqps":
+		return true
+	}
+	query := parsed.Query()
+	switch query.Get("sslmode") {
+	case "require", "verify-ca", "verify-full":
+		return true
+	}
+	return query.Get("tls") == "true" || query.Get("ssl") == "true"
+}
+
+// HandshakeTLS performs a TLS handshake with the server at addr and returns the negotiated state.
+func HandshakeTLS(addr string, config *tls.Config) (tls.ConnectionState, error) {
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: handshakeTimeout}, "tcp", addr, config)
+	if err != nil {
+		return tls.ConnectionState{}, err
+	}
+	defer conn.Close()
+	return conn.ConnectionState(), nil
+}
+
+// ReportTLS logs the negotiated protocol and whether the server certificate was verified.
+func ReportTLS(protocol string, settings *TLS) {
+	log.Printf("TLS protocol %s negotiated, server certificate verified against the binding CA: %t", protocol, settings.Verified)
+}
+
+// TLSVersionName returns the name of a TLS protocol version.
+func TLSVersionName(version uint16) string {
+	switch version {
+	case tls.VersionTLS10:
+		return "TLSv1"
+	case tls.VersionTLS11:
+		return "TLSv1.1"
+	case tls.VersionTLS12:
+		return "TLSv1.2"
+	case tls.VersionTLS13:
+		return "TLSv1.3"
+	default:
+		return fmt.Sprintf("unknown (0x%04x)", version)
+	}
+}
+
+// SkipTLS logs that the TLS check is skipped because TLS isn't enabled for the service.
+func SkipTLS() {
+	log.Printf("Skipping the TLS check: TLS is not enabled for the service")
+}
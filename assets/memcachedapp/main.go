@@ -0,0 +1,224 @@
+/*
+   Copyright 2020 SUSE
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-community/go-cfenv"
+
+	"github.com/SUSE/minibroker-integration-tests/assets/internal/app"
+)
+
+// defaultPort is the Memcached port used when the credentials don't set one.
+const defaultPort = "11211"
+
+// timeout bounds every exchange with the server.
+const timeout = 10 * time.Second
+
+func main() {
+	app.Main(&workload{})
+}
+
+// workload speaks the Memcached text protocol directly, which needs no client library.
+type workload struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	plan   string
+}
+
+func (w *workload) Connect(ctx context.Context, service *cfenv.Service) error {
+	addr, err := address(service.Credentials)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Connecting to %s\n", addr)
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return err
+	}
+	w.conn = conn
+	w.reader = bufio.NewReader(conn)
+	w.plan = service.Plan
+	return nil
+}
+
+// address returns the host:port from the uri or the host and port in the credentials.
+func address(credentials map[string]interface{}) (string, error) {
+	if uri, ok := credentials["uri"].(string); ok {
+		parsed, err := url.Parse(uri)
+		if err != nil {
+			return "", err
+		}
+		port := parsed.Port()
+		if port == "" {
+			port = defaultPort
+		}
+		return net.JoinHostPort(parsed.Hostname(), port), nil
+	}
+	host, ok := credentials["host"].(string)
+	if !ok {
+		return "", fmt.Errorf("neither URI nor host supplied")
+	}
+	port := defaultPort
+	if value, ok := credentials["port"]; ok {
+		port = fmt.Sprint(value)
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+func (w *workload) Run(ctx context.Context) error {
+	return app.RunChecks(ctx, []app.Check{
+		{Name: "version", Run: w.checkVersion},
+		{Name: "set_get", Run: w.checkSetGet},
+		{Name: "delete", Run: w.checkDelete},
+		{Name: "expiry", Run: w.checkExpiry},
+	})
+}
+
+// command sends a command, with the optional data block, and returns the first line of the reply.
+func (w *workload) command(line string, data string) (string, error) {
+	w.conn.SetDeadline(time.Now().Add(timeout))
+	request := line + "\r\n"
+	if data != "" {
+		request += data + "\r\n"
+	}
+	if _, err := w.conn.Write([]byte(request)); err != nil {
+		return "", err
+	}
+	return w.readLine()
+}
+
+func (w *workload) readLine() (string, error) {
+	reply, err := w.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(reply, "\r\n"), nil
+}
+
+func (w *workload) set(key string, value string, expiration int) error {
+	reply, err := w.command(fmt.Sprintf("set %s 0 %d %d", key, expiration, len(value)), value)
+	if err != nil {
+		return err
+	}
+	if reply != "STORED" {
+		return fmt.Errorf("Unexpected reply to set: %q", reply)
+	}
+	return nil
+}
+
+// get returns the value of the key and whether it was found.
+func (w *workload) get(key string) (string, bool, error) {
+	reply, err := w.command("get "+key, "")
+	if err != nil {
+		return "", false, err
+	}
+	if reply == "END" {
+		return "", false, nil
+	}
+	fields := strings.Fields(reply)
+	if len(fields) != 4 || fields[0] != "VALUE" {
+		return "", false, fmt.Errorf("Unexpected reply to get: %q", reply)
+	}
+	value, err := w.readLine()
+	if err != nil {
+		return "", false, err
+	}
+	if end, err := w.readLine(); err != nil {
+		return "", false, err
+	} else if end != "END" {
+		return "", false, fmt.Errorf("Unexpected end of get: %q", end)
+	}
+	return value, true, nil
+}
+
+// checkVersion asserts the server version matches the plan.
+func (w *workload) checkVersion(ctx context.Context) error {
+	reply, err := w.command("version", "")
+	if err != nil {
+		return err
+	}
+	version := strings.TrimPrefix(reply, "VERSION ")
+	fmt.Printf("Server version %q\n", version)
+	return app.CheckPlanVersion(version, w.plan)
+}
+
+func (w *workload) checkSetGet(ctx context.Context) error {
+	key := "mits-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	expectedValue := key
+	if err := w.set(key, expectedValue, 0); err != nil {
+		return err
+	}
+	value, found, err := w.get(key)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("Key %q not found", key)
+	}
+	if value != expectedValue {
+		return fmt.Errorf("Value %q is not the expected %q", value, expectedValue)
+	}
+	return nil
+}
+
+func (w *workload) checkDelete(ctx context.Context) error {
+	key := "mits-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	if err := w.set(key, "deleted", 0); err != nil {
+		return err
+	}
+	reply, err := w.command("delete "+key, "")
+	if err != nil {
+		return err
+	}
+	if reply != "DELETED" {
+		return fmt.Errorf("Unexpected reply to delete: %q", reply)
+	}
+	if _, found, err := w.get(key); err != nil {
+		return err
+	} else if found {
+		return fmt.Errorf("Deleted key %q still found", key)
+	}
+	return nil
+}
+
+func (w *workload) checkExpiry(ctx context.Context) error {
+	key := "mits-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	if err := w.set(key, "expiring", 1); err != nil {
+		return err
+	}
+	// Memcached expires keys with a one second resolution.
+	time.Sleep(2 * time.Second)
+	if _, found, err := w.get(key); err != nil {
+		return err
+	} else if found {
+		return fmt.Errorf("Key %q did not expire", key)
+	}
+	return nil
+}
+
+func (w *workload) Close() error {
+	return w.conn.Close()
+}
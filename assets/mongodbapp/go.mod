@@ -1,11 +0,0 @@
-module github.com/SUSE/minibroker-integration-tests/assets/mongodbapp
-
-go 1.14
-
-require (
-	github.com/cloudfoundry-community/go-cfenv v1.18.0
-	github.com/stretchr/testify v1.5.1 // indirect
-	go.mongodb.org/mongo-driver v1.4.0
-	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9 // indirect
-	golang.org/x/sys v0.0.0-20200223170610-d5e6a3e2c0ae // indirect
-)
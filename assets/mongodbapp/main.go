@@ -18,85 +18,249 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
-	"net/http"
-	"os"
+	"net"
+	"strconv"
+	"time"
 
 	"github.com/cloudfoundry-community/go-cfenv"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+
+	"github.com/SUSE/minibroker-integration-tests/assets/internal/app"
 )
 
+// duplicateKeyCode is the server error code for a unique index violation.
+const duplicateKeyCode = 11000
+
 func main() {
-	serviceName := os.Getenv("SERVICE_NAME")
-	if serviceName == "" {
-		log.Fatal("SERVICE_NAME not set")
-	}
+	app.Main(&workload{})
+}
+
+type workload struct {
+	client     *mongo.Client
+	database   string
+	plan       string
+	replicaSet string
+	hosts      []string
+	tls        *app.TLS
+}
+
+func (w *workload) Connect(ctx context.Context, service *cfenv.Service) error {
+	uriStr := service.Credentials["uri"].(string)
+	fmt.Printf("Connecting to %q\n", uriStr)
 
-	appEnv, err := cfenv.Current()
+	clientOptions := options.Client().ApplyURI(uriStr)
+	if err := clientOptions.Validate(); err != nil {
+		return err
+	}
+	host, _, err := net.SplitHostPort(clientOptions.Hosts[0])
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	mongodbService, err := appEnv.Services.WithName(serviceName)
+	w.hosts = clientOptions.Hosts
+	w.tls, err = app.LoadTLS(service.Credentials, host)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	if w.tls.Enabled {
+		clientOptions.SetTLSConfig(w.tls.Config)
 	}
 
-	uriStr := mongodbService.Credentials["uri"].(string)
-	fmt.Printf("Connecting to %q\n", uriStr)
+	client, err := mongo.Connect(ctx, clientOptions)
+	if err != nil {
+		return err
+	}
+	w.client = client
+	w.database = service.Credentials["database"].(string)
+	w.plan = service.Plan
+	return nil
+}
 
-	ctx := context.Background()
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uriStr))
+func (w *workload) Run(ctx context.Context) error {
+	if err := w.client.Ping(ctx, readpref.Primary()); err != nil {
+		return err
+	}
+
+	return app.RunChecks(ctx, []app.Check{
+		{Name: "topology", Run: w.checkTopology},
+		{Name: "server_version", Run: w.checkServerVersion},
+		{Name: "majority_write", Run: w.checkMajorityWrite},
+		{Name: "unique_index", Run: w.checkUniqueIndex},
+		{Name: "secondary_read", Run: w.checkSecondaryRead},
+		{Name: "tls", Run: w.checkTLS},
+	})
+}
+
+// checkTLS reports the TLS protocol negotiated with the first host when TLS is enabled.
+func (w *workload) checkTLS(ctx context.Context) error {
+	if !w.tls.Enabled {
+		app.SkipTLS()
+		return nil
+	}
+	state, err := app.HandshakeTLS(w.hosts[0], w.tls.Config)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	defer client.Disconnect(ctx)
+	app.ReportTLS(app.TLSVersionName(state.Version), w.tls)
+	return nil
+}
 
-	if err := client.Ping(ctx, readpref.Primary()); err != nil {
-		log.Fatal(err)
+// checkTopology reports whether the server is part of a replica set, which the secondary_read
+// check depends on.
+func (w *workload) checkTopology(ctx context.Context) error {
+	var isMaster struct {
+		IsMaster  bool     `bson:"ismaster"`
+		Secondary bool     `bson:"secondary"`
+		SetName   string   `bson:"setName"`
+		Hosts     []string `bson:"hosts"`
+	}
+	if err := w.client.Database("admin").RunCommand(ctx, bson.M{"isMaster": 1}).Decode(&isMaster); err != nil {
+		return err
+	}
+	w.replicaSet = isMaster.SetName
+	if w.replicaSet == "" {
+		fmt.Printf("Topology: standalone\n")
+		return nil
+	}
+	fmt.Printf("Topology: replica set %q with members %v\n", isMaster.SetName, isMaster.Hosts)
+	return nil
+}
+
+// checkServerVersion asserts the server version matches the plan.
+func (w *workload) checkServerVersion(ctx context.Context) error {
+	var buildInfo struct {
+		Version string `bson:"version"`
+	}
+	if err := w.client.Database(w.database).RunCommand(ctx, bson.M{"buildInfo": 1}).Decode(&buildInfo); err != nil {
+		return err
 	}
+	fmt.Printf("Server version %q\n", buildInfo.Version)
+	return app.CheckPlanVersion(buildInfo.Version, w.plan)
+}
 
-	databaseStr := mongodbService.Credentials["database"].(string)
-	collection := client.Database(databaseStr).Collection("mits")
-	expectedValue := Mits{"12345"}
+// checkMajorityWrite inserts a document acknowledged by the majority of the members and reads it
+// back.
+func (w *workload) checkMajorityWrite(ctx context.Context) error {
+	collection := w.client.Database(w.database).Collection(
+		"mits",
+		options.Collection().SetWriteConcern(writeconcern.New(writeconcern.WMajority())),
+	)
+	expectedValue := Mits{strconv.FormatInt(time.Now().UnixNano(), 10)}
 	if _, err := collection.InsertOne(ctx, expectedValue); err != nil {
-		log.Fatal(err)
+		return err
+	}
+	return findOne(ctx, collection, expectedValue)
+}
+
+// checkUniqueIndex asserts a unique index rejects a duplicate insert.
+func (w *workload) checkUniqueIndex(ctx context.Context) error {
+	collection := w.client.Database(w.database).Collection("mits_unique")
+	index := mongo.IndexModel{
+		Keys:    bson.M{"mits_id": 1},
+		Options: options.Index().SetUnique(true),
+	}
+	if _, err := collection.Indexes().CreateOne(ctx, index); err != nil {
+		return err
+	}
+
+	value := Mits{strconv.FormatInt(time.Now().UnixNano(), 10)}
+	if _, err := collection.InsertOne(ctx, value); err != nil {
+		return err
 	}
+	_, err := collection.InsertOne(ctx, value)
+	if err == nil {
+		return fmt.Errorf("Duplicate insert of %q was not rejected", value.MitsID)
+	}
+	var writeException mongo.WriteException
+	if !errors.As(err, &writeException) {
+		return err
+	}
+	for _, writeError := range writeException.WriteErrors {
+		if writeError.Code == duplicateKeyCode {
+			return nil
+		}
+	}
+	return err
+}
 
-	cursor, err := collection.Find(ctx, bson.D{})
+// checkSecondaryRead reads a document back from a secondary in a causally consistent session, so
+// the secondary must have applied the write. It is skipped without a replica set.
+func (w *workload) checkSecondaryRead(ctx context.Context) error {
+	if w.replicaSet == "" {
+		fmt.Printf("Skipping the secondary read: not a replica set\n")
+		return nil
+	}
+
+	sessionOptions := options.Session().
+		SetCausalConsistency(true).
+		SetDefaultReadConcern(readconcern.Majority()).
+		SetDefaultWriteConcern(writeconcern.New(writeconcern.WMajority()))
+	return w.client.UseSessionWithOptions(ctx, sessionOptions, func(sessionContext mongo.SessionContext) error {
+		expectedValue := Mits{strconv.FormatInt(time.Now().UnixNano(), 10)}
+		if _, err := w.client.Database(w.database).Collection("mits").InsertOne(sessionContext, expectedValue); err != nil {
+			return err
+		}
+		collection := w.client.Database(w.database).Collection(
+			"mits",
+			options.Collection().SetReadPreference(readpref.Secondary()),
+		)
+		return findOne(sessionContext, collection, expectedValue)
+	})
+}
+
+func findOne(ctx context.Context, collection *mongo.Collection, expectedValue Mits) error {
+	cursor, err := collection.Find(ctx, bson.M{"mits_id": expectedValue.MitsID})
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	var results []Mits
 	if err := cursor.All(ctx, &results); err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	if len(results) != 1 {
-		log.Fatal(fmt.Errorf("Invalid result length: %d, expected 1", len(results)))
+		return fmt.Errorf("Invalid result length: %d, expected 1", len(results))
 	}
 
 	value := results[0]
 
 	if value.MitsID != expectedValue.MitsID {
-		log.Fatal(fmt.Errorf("Value %q is not the expected %q", value, expectedValue))
+		return fmt.Errorf("Value %q is not the expected %q", value, expectedValue)
 	}
 
-	port, exists := os.LookupEnv("PORT")
-	if !exists {
-		port = "8080"
-	}
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprintf(w, "ok")
-	})
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%s", port), nil))
+	return nil
+}
+
+func (w *workload) Close() error {
+	return w.client.Disconnect(context.Background())
 }
 
 // Mits represents an instance to be inserted in the mits collection.
 type Mits struct {
-	MitsID string `json:"mits_id"`
+	MitsID string `json:"mits_id" bson:"mits_id"`
+}
+
+func (w *workload) Seed(ctx context.Context, value string) error {
+	collection := w.client.Database(w.database).Collection("mits_seed")
+	_, err := collection.InsertOne(ctx, Mits{value})
+	return err
+}
+
+func (w *workload) Verify(ctx context.Context, value string) error {
+	collection := w.client.Database(w.database).Collection("mits_seed")
+	count, err := collection.CountDocuments(ctx, bson.M{"mits_id": value})
+	if err != nil {
+		return err
+	}
+	if count != 1 {
+		return fmt.Errorf("Seeded value %q found %d times, expected 1", value, count)
+	}
+	return nil
 }
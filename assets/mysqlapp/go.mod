@@ -1,8 +0,0 @@
-module github.com/SUSE/minibroker-integration-tests/assets/mysqlapp
-
-go 1.14
-
-require (
-	github.com/cloudfoundry-community/go-cfenv v1.18.0
-	github.com/go-sql-driver/mysql v1.5.0
-)
@@ -17,76 +17,290 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
-	"net/http"
+	"net"
 	"net/url"
-	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/cloudfoundry-community/go-cfenv"
 	"github.com/go-sql-driver/mysql"
+
+	"github.com/SUSE/minibroker-integration-tests/assets/internal/app"
 )
 
+// defaultPort is the MySQL port used when the service URI doesn't set one.
+const defaultPort = "3306"
+
+// multibyteValue is round-tripped to assert the table charset handles more than ASCII.
+const multibyteValue = "mits ✓ ünïcødé 日本"
+
 func main() {
-	serviceName := os.Getenv("SERVICE_NAME")
-	if serviceName == "" {
-		log.Fatal("SERVICE_NAME not set")
-	}
+	app.Main(&workload{})
+}
 
-	appEnv, err := cfenv.Current()
+// tlsConfigKey is the name the TLS config is registered under with the driver.
+const tlsConfigKey = "mits"
+
+type workload struct {
+	db   *sql.DB
+	plan string
+	tls  *app.TLS
+}
+
+func (w *workload) Connect(ctx context.Context, service *cfenv.Service) error {
+	config, err := parseURI(service.Credentials)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Connecting to %s@%s/%s\n", config.User, config.Addr, config.DBName)
+	host, _, err := net.SplitHostPort(config.Addr)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	mysqlService, err := appEnv.Services.WithName(serviceName)
+	w.tls, err = app.LoadTLS(service.Credentials, host)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	if w.tls.Enabled {
+		if err := mysql.RegisterTLSConfig(tlsConfigKey, w.tls.Config); err != nil {
+			return err
+		}
+		config.TLSConfig = tlsConfigKey
 	}
 
-	spec, ok := mysqlService.Credentials["uri"].(string)
+	db, err := sql.Open("mysql", config.FormatDSN())
+	if err != nil {
+		return err
+	}
+	w.db = db
+	w.plan = service.Plan
+	return nil
+}
+
+// tlsOptions are the URI query options handled by app.LoadTLS rather than the driver.
+var tlsOptions = []string{"tls", "ssl", "sslmode"}
+
+// parseURI builds the driver config from the URI in the credentials, keeping the user, password
+// and port apart. The query options are passed to the driver as DSN params.
+func parseURI(credentials map[string]interface{}) (*mysql.Config, error) {
+	spec, ok := credentials["uri"].(string)
 	if !ok {
-		log.Fatal(fmt.Errorf("URI not supplied"))
+		return nil, fmt.Errorf("URI not supplied")
 	}
 	uri, err := url.Parse(spec)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+	var user, password string
+	if uri.User != nil {
+		user = uri.User.Username()
+		password, _ = uri.User.Password()
+	}
+	port := uri.Port()
+	if port == "" {
+		port = defaultPort
+	}
+	dsn := fmt.Sprintf(
+		"%s:%s@tcp(%s)/%s",
+		user, password, net.JoinHostPort(uri.Hostname(), port), strings.TrimPrefix(uri.Path, "/"),
+	)
+	query := uri.Query()
+	for _, option := range tlsOptions {
+		query.Del(option)
+	}
+	if len(query) > 0 {
+		dsn += "?" + query.Encode()
+	}
+	return mysql.ParseDSN(dsn)
+}
+
+func (w *workload) Run(ctx context.Context) error {
+	if err := w.db.PingContext(ctx); err != nil {
+		return err
+	}
+
+	if _, err := w.db.ExecContext(ctx, createTableStatement); err != nil {
+		return err
+	}
+
+	return app.RunChecks(ctx, []app.Check{
+		{Name: "server", Run: w.checkServer},
+		{Name: "charset", Run: w.checkValueCharset},
+		{Name: "crud", Run: w.checkCRUD},
+		{Name: "rollback", Run: w.checkRollback},
+		{Name: "tls", Run: w.checkTLS},
+	})
+}
+
+// checkTLS asserts the connection uses TLS and reports the negotiated protocol when TLS is enabled.
+func (w *workload) checkTLS(ctx context.Context) error {
+	if !w.tls.Enabled {
+		app.SkipTLS()
+		return nil
+	}
+	var name, version string
+	if err := w.db.QueryRowContext(ctx, "SHOW SESSION STATUS LIKE 'Ssl_version'").Scan(&name, &version); err != nil {
+		return err
+	}
+	if version == "" {
+		return fmt.Errorf("Connection is not using TLS")
+	}
+	app.ReportTLS(version, w.tls)
+	return nil
+}
+
+// checkServer asserts the server version matches the plan, when the plan is named after a version.
+// The database charset is only reported, since it depends on the chart defaults.
+func (w *workload) checkServer(ctx context.Context) error {
+	var version, charset string
+	row := w.db.QueryRowContext(ctx, "SELECT VERSION(), @@character_set_database")
+	if err := row.Scan(&version, &charset); err != nil {
+		return err
+	}
+	fmt.Printf("Server version %q, database charset %q\n", version, charset)
+	return app.CheckPlanVersion(version, w.plan)
+}
+
+// checkValueCharset asserts the charset of the mits.value column, which the multibyte value is
+// round-tripped through, is a UTF-8 one.
+func (w *workload) checkValueCharset(ctx context.Context) error {
+	var charset string
+	row := w.db.QueryRowContext(ctx, `SELECT CHARACTER_SET_NAME FROM information_schema.COLUMNS
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'mits' AND COLUMN_NAME = 'value'`)
+	if err := row.Scan(&charset); err != nil {
+		return err
 	}
-	config := mysql.NewConfig()
-	config.User = uri.User.String()
-	config.Net = "tcp"
-	config.Addr = uri.Hostname()
-	config.DBName = strings.TrimPrefix(uri.Path, "/")
-	uriStr := config.FormatDSN()
-	fmt.Printf("Connecting to %q\n", uriStr)
+	fmt.Printf("Column mits.value charset %q\n", charset)
+	return checkCharset(charset)
+}
 
-	db, err := sql.Open("mysql", uriStr)
+// utf8Charsets are the names of the UTF-8 charsets. MySQL 8 reports utf8 as utf8mb3.
+var utf8Charsets = []string{"utf8", "utf8mb3", "utf8mb4"}
+
+// checkCharset asserts the charset is a UTF-8 one.
+func checkCharset(charset string) error {
+	for _, utf8Charset := range utf8Charsets {
+		if strings.EqualFold(charset, utf8Charset) {
+			return nil
+		}
+	}
+	return fmt.Errorf("Charset %q is not a UTF-8 charset", charset)
+}
+
+// checkCRUD inserts, selects, updates and deletes a row. The update round-trips a multibyte value
+// through the utf8mb4 column.
+func (w *workload) checkCRUD(ctx context.Context) error {
+	value := strconv.FormatInt(time.Now().UnixNano(), 10)
+	result, err := w.db.ExecContext(ctx, "INSERT INTO mits (value) VALUES (?)", value)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	defer db.Close()
 
-	if err := db.Ping(); err != nil {
-		log.Fatal(err)
+	if err := w.expectValue(ctx, id, value); err != nil {
+		return err
 	}
 
-	if _, err := db.Exec(createTableStatement); err != nil {
-		log.Fatal(err)
+	if _, err := w.db.ExecContext(ctx, "UPDATE mits SET value = ? WHERE id = ?", multibyteValue, id); err != nil {
+		return err
+	}
+	if err := w.expectValue(ctx, id, multibyteValue); err != nil {
+		return err
 	}
 
-	port, exists := os.LookupEnv("PORT")
-	if !exists {
-		port = "8080"
+	if _, err := w.db.ExecContext(ctx, "DELETE FROM mits WHERE id = ?", id); err != nil {
+		return err
 	}
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprintf(w, "ok")
-	})
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%s", port), nil))
+	var count int
+	row := w.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM mits WHERE id = ?", id)
+	if err := row.Scan(&count); err != nil {
+		return err
+	}
+	if count != 0 {
+		return fmt.Errorf("Deleted row %d still found", id)
+	}
+	return nil
+}
+
+func (w *workload) expectValue(ctx context.Context, id int64, expectedValue string) error {
+	var value string
+	row := w.db.QueryRowContext(ctx, "SELECT value FROM mits WHERE id = ?", id)
+	if err := row.Scan(&value); err != nil {
+		return err
+	}
+	if value != expectedValue {
+		return fmt.Errorf("Value %q is not the expected %q", value, expectedValue)
+	}
+	return nil
+}
+
+// checkRollback asserts that a row inserted in a rolled back transaction is not persisted.
+func (w *workload) checkRollback(ctx context.Context) error {
+	value := strconv.FormatInt(time.Now().UnixNano(), 10)
+	tx, err := w.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO mits (value) VALUES (?)", value); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Rollback(); err != nil {
+		return err
+	}
+	var count int
+	row := w.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM mits WHERE value = ?", value)
+	if err := row.Scan(&count); err != nil {
+		return err
+	}
+	if count != 0 {
+		return fmt.Errorf("Value %q inserted in a rolled back transaction was persisted", value)
+	}
+	return nil
+}
+
+func (w *workload) Close() error {
+	return w.db.Close()
 }
 
+func (w *workload) Seed(ctx context.Context, value string) error {
+	if _, err := w.db.ExecContext(ctx, createSeedTableStatement); err != nil {
+		return err
+	}
+	_, err := w.db.ExecContext(ctx, "INSERT INTO mits_seed (value) VALUES (?)", value)
+	return err
+}
+
+func (w *workload) Verify(ctx context.Context, value string) error {
+	var count int
+	row := w.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM mits_seed WHERE value = ?", value)
+	if err := row.Scan(&count); err != nil {
+		return err
+	}
+	if count != 1 {
+		return fmt.Errorf("Seeded value %q found %d times, expected 1", value, count)
+	}
+	return nil
+}
+
+// The table uses InnoDB for the transactions and utf8mb4 for the multibyte value.
 const createTableStatement = `
-CREATE Table mits(
+CREATE Table IF NOT EXISTS mits(
 	id int NOT NULL AUTO_INCREMENT,
+	value varchar(255) CHARACTER SET utf8mb4 NOT NULL,
 	PRIMARY KEY (id)
+) ENGINE=InnoDB;
+`
+
+const createSeedTableStatement = `
+CREATE Table IF NOT EXISTS mits_seed(
+	value varchar(255) NOT NULL
 );
 `
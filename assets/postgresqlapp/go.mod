@@ -1,9 +0,0 @@
-module github.com/SUSE/minibroker-integration-tests/assets/postgresqlapp
-
-go 1.14
-
-require (
-	github.com/cloudfoundry-community/go-cfenv v1.18.0
-	github.com/jackc/pgx/v4 v4.8.1
-	github.com/lib/pq v1.8.0 // indirect
-)
@@ -19,60 +19,255 @@ package main
 import (
 	"context"
 	"fmt"
-	"log"
-	"net/http"
-	"os"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/cloudfoundry-community/go-cfenv"
 	pgx "github.com/jackc/pgx/v4"
+
+	"github.com/SUSE/minibroker-integration-tests/assets/internal/app"
 )
 
 func main() {
-	serviceName := os.Getenv("SERVICE_NAME")
-	if serviceName == "" {
-		log.Fatal("SERVICE_NAME not set")
+	app.Main(&workload{})
+}
+
+type workload struct {
+	db       *pgx.Conn
+	plan     string
+	database string
+	tls      *app.TLS
+}
+
+func (w *workload) Connect(ctx context.Context, service *cfenv.Service) error {
+	uriStr, ok := service.Credentials["uri"].(string)
+	if !ok {
+		return fmt.Errorf("URI not supplied")
 	}
+	uri, err := url.Parse(uriStr)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Connecting to %s@%s%s\n", uri.User.Username(), uri.Host, uri.Path)
 
-	appEnv, err := cfenv.Current()
+	config, err := pgx.ParseConfig(uriStr)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	postgresqlService, err := appEnv.Services.WithName(serviceName)
+	w.tls, err = app.LoadTLS(service.Credentials, config.Host)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	if w.tls.Enabled {
+		// Never fall back to a plaintext connection.
+		config.TLSConfig = w.tls.Config
+		config.Fallbacks = nil
 	}
 
-	uriStr := postgresqlService.Credentials["uri"].(string)
-	fmt.Printf("Connecting to %q\n", uriStr)
+	db, err := pgx.ConnectConfig(ctx, config)
+	if err != nil {
+		return err
+	}
+	w.db = db
+	w.plan = service.Plan
+	w.database = strings.TrimPrefix(uri.Path, "/")
+	if database, ok := service.Credentials["database"].(string); ok {
+		w.database = database
+	}
+	return nil
+}
+
+func (w *workload) Run(ctx context.Context) error {
+	if err := w.db.Ping(ctx); err != nil {
+		return err
+	}
 
-	ctx := context.Background()
+	if _, err := w.db.Exec(ctx, createTableStatement); err != nil {
+		return err
+	}
 
-	db, err := pgx.Connect(ctx, uriStr)
+	return app.RunChecks(ctx, []app.Check{
+		{Name: "server_version", Run: w.checkServerVersion},
+		{Name: "ownership", Run: w.checkOwnership},
+		{Name: "create_schema", Run: w.checkCreateSchema},
+		{Name: "crud", Run: w.checkCRUD},
+		{Name: "rollback", Run: w.checkRollback},
+		{Name: "tls", Run: w.checkTLS},
+	})
+}
+
+// checkTLS asserts the connection uses TLS and reports the negotiated protocol when TLS is enabled.
+func (w *workload) checkTLS(ctx context.Context) error {
+	if !w.tls.Enabled {
+		app.SkipTLS()
+		return nil
+	}
+	var ssl bool
+	var version string
+	row := w.db.QueryRow(ctx, "SELECT ssl, COALESCE(version, '') FROM pg_stat_ssl WHERE pid = pg_backend_pid()")
+	if err := row.Scan(&ssl, &version); err != nil {
+		return err
+	}
+	if !ssl {
+		return fmt.Errorf("Connection is not using TLS")
+	}
+	app.ReportTLS(version, w.tls)
+	return nil
+}
+
+// checkServerVersion asserts the server version matches the plan.
+func (w *workload) checkServerVersion(ctx context.Context) error {
+	var version string
+	if err := w.db.QueryRow(ctx, "SHOW server_version").Scan(&version); err != nil {
+		return err
+	}
+	fmt.Printf("Server version %q\n", version)
+	return app.CheckPlanVersion(version, w.plan)
+}
+
+// checkOwnership asserts the bound user is connected to the database named in the credentials and
+// owns it.
+func (w *workload) checkOwnership(ctx context.Context) error {
+	var database, user, owner string
+	row := w.db.QueryRow(ctx, `
+		SELECT current_database(), current_user, pg_catalog.pg_get_userbyid(datdba)
+		FROM pg_catalog.pg_database
+		WHERE datname = current_database()
+	`)
+	if err := row.Scan(&database, &user, &owner); err != nil {
+		return err
+	}
+	if database != w.database {
+		return fmt.Errorf("Connected to database %q, expected %q", database, w.database)
+	}
+	if owner != user {
+		return fmt.Errorf("Database %q is owned by %q, not by the bound user %q", database, owner, user)
+	}
+	return nil
+}
+
+// checkCreateSchema asserts the bound user can create and drop a schema.
+func (w *workload) checkCreateSchema(ctx context.Context) error {
+	schema := pgx.Identifier{"mits_" + strconv.FormatInt(time.Now().UnixNano(), 10)}.Sanitize()
+	if _, err := w.db.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		return err
+	}
+	_, err := w.db.Exec(ctx, "DROP SCHEMA "+schema)
+	return err
+}
+
+// checkCRUD inserts, selects, updates and deletes a row in a committed transaction.
+func (w *workload) checkCRUD(ctx context.Context) error {
+	value := strconv.FormatInt(time.Now().UnixNano(), 10)
+	tx, err := w.db.Begin(ctx)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var id int64
+	if err := tx.QueryRow(ctx, "INSERT INTO mits (value) VALUES ($1) RETURNING id", value).Scan(&id); err != nil {
+		return err
+	}
+	if err := expectValue(ctx, tx, id, value); err != nil {
+		return err
+	}
+	updatedValue := value + "-updated"
+	if _, err := tx.Exec(ctx, "UPDATE mits SET value = $1 WHERE id = $2", updatedValue, id); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
 	}
-	defer db.Close(ctx)
 
-	if err := db.Ping(ctx); err != nil {
-		log.Fatal(err)
+	if err := expectValue(ctx, w.db, id, updatedValue); err != nil {
+		return err
+	}
+	tag, err := w.db.Exec(ctx, "DELETE FROM mits WHERE id = $1", id)
+	if err != nil {
+		return err
 	}
+	if tag.RowsAffected() != 1 {
+		return fmt.Errorf("Deleted %d rows, expected 1", tag.RowsAffected())
+	}
+	return nil
+}
+
+// querier is implemented by both connections and transactions.
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
 
-	if _, err := db.Exec(ctx, createTableStatement); err != nil {
-		log.Fatal(err)
+func expectValue(ctx context.Context, db querier, id int64, expectedValue string) error {
+	var value string
+	if err := db.QueryRow(ctx, "SELECT value FROM mits WHERE id = $1", id).Scan(&value); err != nil {
+		return err
 	}
+	if value != expectedValue {
+		return fmt.Errorf("Value %q is not the expected %q", value, expectedValue)
+	}
+	return nil
+}
 
-	port, exists := os.LookupEnv("PORT")
-	if !exists {
-		port = "8080"
+// checkRollback asserts that a row inserted in a rolled back transaction is not persisted.
+func (w *workload) checkRollback(ctx context.Context) error {
+	value := strconv.FormatInt(time.Now().UnixNano(), 10)
+	tx, err := w.db.Begin(ctx)
+	if err != nil {
+		return err
 	}
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprintf(w, "ok")
-	})
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%s", port), nil))
+	if _, err := tx.Exec(ctx, "INSERT INTO mits (value) VALUES ($1)", value); err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+	if err := tx.Rollback(ctx); err != nil {
+		return err
+	}
+	var count int
+	if err := w.db.QueryRow(ctx, "SELECT COUNT(*) FROM mits WHERE value = $1", value).Scan(&count); err != nil {
+		return err
+	}
+	if count != 0 {
+		return fmt.Errorf("Value %q inserted in a rolled back transaction was persisted", value)
+	}
+	return nil
+}
+
+func (w *workload) Close() error {
+	return w.db.Close(context.Background())
+}
+
+func (w *workload) Seed(ctx context.Context, value string) error {
+	if _, err := w.db.Exec(ctx, createSeedTableStatement); err != nil {
+		return err
+	}
+	_, err := w.db.Exec(ctx, "INSERT INTO mits_seed (value) VALUES ($1)", value)
+	return err
+}
+
+func (w *workload) Verify(ctx context.Context, value string) error {
+	var count int
+	row := w.db.QueryRow(ctx, "SELECT COUNT(*) FROM mits_seed WHERE value = $1", value)
+	if err := row.Scan(&count); err != nil {
+		return err
+	}
+	if count != 1 {
+		return fmt.Errorf("Seeded value %q found %d times, expected 1", value, count)
+	}
+	return nil
 }
 
 const createTableStatement = `
-CREATE Table mits(
-	id SERIAL
+CREATE Table IF NOT EXISTS mits(
+	id SERIAL PRIMARY KEY,
+	value text NOT NULL
+);
+`
+
+const createSeedTableStatement = `
+CREATE Table IF NOT EXISTS mits_seed(
+	value text NOT NULL
 );
 `
@@ -0,0 +1,185 @@
+/*
+   Copyright 2020 SUSE
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/cloudfoundry-community/go-cfenv"
+
+	"github.com/SUSE/minibroker-integration-tests/assets/internal/app"
+)
+
+// dialTimeout bounds the TCP connections to the service.
+const dialTimeout = 10 * time.Second
+
+// readTimeout is how long a banner or a reply to a probe is waited for.
+const readTimeout = 3 * time.Second
+
+// httpProbe is sent to the servers that don't send a banner, as most protocols reply to it with an
+// error that identifies them.
+const httpProbe = "HEAD / HTTP/1.0\r\n\r\n"
+
+func main() {
+	app.Main(&workload{})
+}
+
+// workload is the fallback for the service classes without a dedicated asset app. It connects to
+// the host and port from any binding and detects the protocol spoken by the server where it can.
+// Only the connectivity is asserted, so the detected protocol is reported but never fails a check.
+type workload struct {
+	addr string
+	tls  *app.TLS
+}
+
+func (w *workload) Connect(ctx context.Context, service *cfenv.Service) error {
+	host, port, err := hostPort(service.Credentials)
+	if err != nil {
+		return err
+	}
+	w.addr = net.JoinHostPort(host, port)
+	fmt.Printf("Probing %s\n", w.addr)
+	w.tls, err = app.LoadTLS(service.Credentials, host)
+	return err
+}
+
+// hostPort returns the host and port from the credentials, preferring the host and port keys over
+// the uri since some services set a uri without a port.
+func hostPort(credentials map[string]interface{}) (string, string, error) {
+	host, _ := credentials["host"].(string)
+	if host == "" {
+		host, _ = credentials["hostname"].(string)
+	}
+	var port string
+	if value, ok := credentials["port"]; ok {
+		port = fmt.Sprint(value)
+	}
+	if uri, ok := credentials["uri"].(string); ok {
+		parsed, err := url.Parse(uri)
+		if err != nil {
+			return "", "", err
+		}
+		if host == "" {
+			host = parsed.Hostname()
+		}
+		if port == "" {
+			port = parsed.Port()
+		}
+	}
+	if host == "" {
+		return "", "", fmt.Errorf("neither URI nor host supplied")
+	}
+	if port == "" {
+		return "", "", fmt.Errorf("no port supplied for %s", host)
+	}
+	return host, port, nil
+}
+
+func (w *workload) Run(ctx context.Context) error {
+	return app.RunChecks(ctx, []app.Check{
+		{Name: "connect", Run: w.checkConnect},
+		{Name: "tls", Run: w.checkTLS},
+	})
+}
+
+// checkConnect opens a TCP connection to the service and reports the protocol detected from the
+// banner sent by the server, or else from its reply to an HTTP request.
+func (w *workload) checkConnect(ctx context.Context) error {
+	conn, err := net.DialTimeout("tcp", w.addr, dialTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	banner := read(conn)
+	if len(banner) == 0 {
+		conn.SetWriteDeadline(time.Now().Add(readTimeout))
+		if _, err := conn.Write([]byte(httpProbe)); err != nil {
+			return fmt.Errorf("failed to write the probe: %w", err)
+		}
+		banner = read(conn)
+	}
+	fmt.Printf("Detected protocol %q from a %d bytes reply\n", detectProtocol(banner), len(banner))
+	return nil
+}
+
+// read returns what the server sends within the readTimeout, if anything.
+func read(conn net.Conn) []byte {
+	conn.SetReadDeadline(time.Now().Add(readTimeout))
+	buf := make([]byte, 512)
+	n, _ := conn.Read(buf)
+	return buf[:n]
+}
+
+// detectProtocol guesses the protocol from a banner or a reply to the httpProbe.
+func detectProtocol(reply []byte) string {
+	prefixes := []struct {
+		prefix   string
+		protocol string
+	}{
+		{"SSH-", "ssh"},
+		{"HTTP/", "http"},
+		{"AMQP", "amqp"},
+		{"+OK", "pop3"},
+		{"* OK", "imap"},
+		{"220", "smtp or ftp"},
+		{"-ERR", "redis"},
+		{"-NOAUTH", "redis"},
+		{"ERROR", "memcached"},
+	}
+	for _, p := range prefixes {
+		if bytes.HasPrefix(reply, []byte(p.prefix)) {
+			return p.protocol
+		}
+	}
+	switch {
+	case len(reply) == 0:
+		return "unknown, no reply"
+	case len(reply) > 4 && reply[4] == 10:
+		// The MySQL initial handshake packet has a 4 bytes header followed by the protocol
+		// version 10.
+		return "mysql"
+	case len(reply) > 1 && (reply[0] == 0x15 || reply[0] == 0x16) && reply[1] == 0x03:
+		// A TLS alert or handshake record.
+		return "tls"
+	default:
+		return "unknown"
+	}
+}
+
+// checkTLS asserts the TLS handshake succeeds when the credentials require TLS.
+func (w *workload) checkTLS(ctx context.Context) error {
+	if !w.tls.Enabled {
+		app.SkipTLS()
+		return nil
+	}
+	state, err := app.HandshakeTLS(w.addr, w.tls.Config)
+	if err != nil {
+		return err
+	}
+	app.ReportTLS(app.TLSVersionName(state.Version), w.tls)
+	return nil
+}
+
+func (w *workload) Close() error {
+	return nil
+}
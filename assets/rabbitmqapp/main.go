@@ -17,99 +17,442 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"log"
-	"net/http"
-	"os"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/cloudfoundry-community/go-cfenv"
 	"github.com/streadway/amqp"
+
+	"github.com/SUSE/minibroker-integration-tests/assets/internal/app"
 )
 
+// receiveTimeout bounds the wait for any confirmation or delivery, so a lost message fails the
+// check instead of hanging the app.
+const receiveTimeout = 10 * time.Second
+
 func main() {
-	serviceName := os.Getenv("SERVICE_NAME")
-	if serviceName == "" {
-		log.Fatal("SERVICE_NAME not set")
+	app.Main(&workload{})
+}
+
+type workload struct {
+	conn  *amqp.Connection
+	ch    *amqp.Channel
+	vhost string
+	tls   *app.TLS
+}
+
+func (w *workload) Connect(ctx context.Context, service *cfenv.Service) error {
+	uriStr, ok := service.Credentials["uri"].(string)
+	if !ok {
+		return fmt.Errorf("URI not supplied")
+	}
+	uri, err := url.Parse(uriStr)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Connecting to %s@%s%s\n", uri.User.Username(), uri.Host, uri.Path)
+	w.tls, err = app.LoadTLS(service.Credentials, uri.Hostname())
+	if err != nil {
+		return err
+	}
+	var conn *amqp.Connection
+	if w.tls.Enabled {
+		conn, err = amqp.DialTLS(amqpsURI(uri, service.Credentials), w.tls.Config)
+	} else {
+		conn, err = amqp.Dial(uriStr)
+	}
+	if err != nil {
+		return err
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	w.conn = conn
+	w.ch = ch
+	// As per the AMQP URI spec, an empty path selects the default vhost.
+	w.vhost = "/"
+	if uri.Path != "" {
+		w.vhost = strings.TrimPrefix(uri.Path, "/")
+	}
+	if vhost, ok := service.Credentials["vhost"].(string); ok {
+		w.vhost = vhost
+	}
+	return nil
+}
+
+// amqpsPort is the port RabbitMQ listens on for AMQP over TLS by default.
+const amqpsPort = "5671"
+
+// amqpsURI returns the uri switched to the amqps scheme. A uri already using it is kept as-is.
+// Otherwise, its plain AMQP port is replaced by the tls_port from the credentials, if any, or by
+// amqpsPort.
+func amqpsURI(uri *url.URL, credentials map[string]interface{}) string {
+	if uri.Scheme == "amqps" {
+		return uri.String()
+	}
+	port := amqpsPort
+	switch tlsPort := credentials["tls_port"].(type) {
+	case string:
+		port = tlsPort
+	case float64:
+		port = strconv.Itoa(int(tlsPort))
+	}
+	tlsURI := *uri
+	tlsURI.Scheme = "amqps"
+	tlsURI.Host = net.JoinHostPort(uri.Hostname(), port)
+	return tlsURI.String()
+}
+
+func (w *workload) Run(ctx context.Context) error {
+	return app.RunChecks(ctx, []app.Check{
+		{Name: "vhost", Run: w.checkVhost},
+		{Name: "durable_queue", Run: w.checkDurableQueue},
+		{Name: "topic_exchange", Run: w.checkTopicExchange},
+		{Name: "fanout_exchange", Run: w.checkFanoutExchange},
+		{Name: "redelivery", Run: w.checkRedelivery},
+		{Name: "tls", Run: w.checkTLS},
+	})
+}
+
+// checkTLS reports the TLS protocol negotiated by the connection when TLS is enabled.
+func (w *workload) checkTLS(ctx context.Context) error {
+	if !w.tls.Enabled {
+		app.SkipTLS()
+		return nil
+	}
+	state := w.conn.ConnectionState()
+	if !state.HandshakeComplete {
+		return fmt.Errorf("TLS handshake not completed")
 	}
+	app.ReportTLS(app.TLSVersionName(state.Version), w.tls)
+	return nil
+}
+
+// checkVhost asserts the connection was opened on the vhost from the credentials.
+func (w *workload) checkVhost(ctx context.Context) error {
+	if w.conn.Config.Vhost != w.vhost {
+		return fmt.Errorf("Connected to vhost %q, expected %q", w.conn.Config.Vhost, w.vhost)
+	}
+	return nil
+}
+
+// checkDurableQueue publishes a persistent message to a durable queue and consumes it.
+func (w *workload) checkDurableQueue(ctx context.Context) error {
+	ch, err := w.confirmChannel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
 
-	appEnv, err := cfenv.Current()
+	name := "mits-durable-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	if _, err := ch.QueueDeclare(name, true, false, false, false, nil); err != nil {
+		return err
+	}
+	defer ch.QueueDelete(name, false, false, false)
+	if err := w.expectDurable(name); err != nil {
+		return err
+	}
+
+	expectedValue := name
+	if err := ch.publish(ctx, "", name, expectedValue); err != nil {
+		return err
+	}
+	msgs, err := ch.Consume(name, "", false, false, false, false, nil)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	amqpEnv, err := appEnv.Services.WithName(serviceName)
+	msg, err := receive(ctx, msgs)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
+	if err := msg.Ack(false); err != nil {
+		return err
+	}
+	return expectBody(msg, expectedValue)
+}
 
-	uriStr := amqpEnv.Credentials["uri"].(string)
-	fmt.Printf("Connecting to %q\n", uriStr)
-	conn, err := amqp.Dial(uriStr)
+// expectDurable asserts the queue is durable. A passive declaration ignores the durable flag, so
+// the queue is redeclared as non-durable instead, which the server rejects with PRECONDITION_FAILED
+// when the flags don't match the existing queue. The server closes the channel on the error, so a
+// throwaway channel is used.
+func (w *workload) expectDurable(name string) error {
+	ch, err := w.conn.Channel()
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	defer ch.Close()
+	_, err = ch.QueueDeclare(name, false, false, false, false, nil)
+	if err == nil {
+		return fmt.Errorf("Queue %q is not durable", name)
 	}
-	defer conn.Close()
+	if amqpErr, ok := err.(*amqp.Error); !ok || amqpErr.Code != amqp.PreconditionFailed {
+		return fmt.Errorf("Unexpected error redeclaring queue %q as non-durable: %w", name, err)
+	}
+	return nil
+}
 
-	ch, err := conn.Channel()
+// checkTopicExchange asserts a topic exchange only routes the messages matching the binding key.
+func (w *workload) checkTopicExchange(ctx context.Context) error {
+	ch, err := w.confirmChannel()
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	defer ch.Close()
 
-	queue, err := ch.QueueDeclare(
-		"foo", // name
-		false, // durable
-		false, // delete when unused
-		false, // exclusive
-		false, // no-wait
-		nil,   // arguments
-	)
+	// The exchange is unique to the check, since apps sharing the instance delete their own.
+	exchange := "mits-topic-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	if err := ch.ExchangeDeclare(exchange, amqp.ExchangeTopic, true, false, false, false, nil); err != nil {
+		return err
+	}
+	defer ch.ExchangeDelete(exchange, false, false)
+	queue, err := ch.declareTemporaryQueue(exchange, "mits.*.check")
+	if err != nil {
+		return err
+	}
+	msgs, err := ch.Consume(queue.Name, "", true, true, false, false, nil)
+	if err != nil {
+		return err
+	}
+
+	expectedValue := strconv.FormatInt(time.Now().UnixNano(), 10)
+	if err := ch.publish(ctx, exchange, "other.topic.check", "unexpected "+expectedValue); err != nil {
+		return err
+	}
+	if err := ch.publish(ctx, exchange, "mits.topic.check", expectedValue); err != nil {
+		return err
+	}
+	msg, err := receive(ctx, msgs)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
+	return expectBody(msg, expectedValue)
+}
 
-	expectedValue := "Hello World!"
+// checkFanoutExchange asserts a fanout exchange routes a message to every bound queue.
+func (w *workload) checkFanoutExchange(ctx context.Context) error {
+	ch, err := w.confirmChannel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
 
-	go func() {
-		if err := ch.Publish(
-			"",         // exchange
-			queue.Name, // routing key
-			false,      // mandatory
-			false,      // immediate
-			amqp.Publishing{
-				ContentType: "text/plain",
-				Body:        []byte(expectedValue),
-			},
-		); err != nil {
-			log.Fatal(err)
+	exchange := "mits-fanout-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	if err := ch.ExchangeDeclare(exchange, amqp.ExchangeFanout, true, false, false, false, nil); err != nil {
+		return err
+	}
+	defer ch.ExchangeDelete(exchange, false, false)
+	var deliveries []<-chan amqp.Delivery
+	for i := 0; i < 2; i++ {
+		queue, err := ch.declareTemporaryQueue(exchange, "")
+		if err != nil {
+			return err
+		}
+		msgs, err := ch.Consume(queue.Name, "", true, true, false, false, nil)
+		if err != nil {
+			return err
+		}
+		deliveries = append(deliveries, msgs)
+	}
+
+	expectedValue := strconv.FormatInt(time.Now().UnixNano(), 10)
+	if err := ch.publish(ctx, exchange, "", expectedValue); err != nil {
+		return err
+	}
+	for _, msgs := range deliveries {
+		msg, err := receive(ctx, msgs)
+		if err != nil {
+			return err
 		}
-	}()
-
-	msgs, err := ch.Consume(
-		queue.Name, // queue
-		"",         // consumer
-		true,       // auto-ack
-		false,      // exclusive
-		false,      // no-local
-		false,      // no-wait
-		nil,        // args
+		if err := expectBody(msg, expectedValue); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkRedelivery asserts a message rejected with a manual nack is redelivered.
+func (w *workload) checkRedelivery(ctx context.Context) error {
+	ch, err := w.confirmChannel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	queue, err := ch.QueueDeclare("", false, true, true, false, nil)
+	if err != nil {
+		return err
+	}
+	expectedValue := strconv.FormatInt(time.Now().UnixNano(), 10)
+	if err := ch.publish(ctx, "", queue.Name, expectedValue); err != nil {
+		return err
+	}
+	msgs, err := ch.Consume(queue.Name, "", false, true, false, false, nil)
+	if err != nil {
+		return err
+	}
+
+	msg, err := receive(ctx, msgs)
+	if err != nil {
+		return err
+	}
+	if err := msg.Nack(false, true); err != nil {
+		return err
+	}
+	msg, err = receive(ctx, msgs)
+	if err != nil {
+		return err
+	}
+	if err := msg.Ack(false); err != nil {
+		return err
+	}
+	if !msg.Redelivered {
+		return fmt.Errorf("Nacked message was not flagged as redelivered")
+	}
+	return expectBody(msg, expectedValue)
+}
+
+// confirmChannel is a channel in confirm mode, so every publishing is acknowledged by the server.
+type confirmChannel struct {
+	*amqp.Channel
+	confirms chan amqp.Confirmation
+}
+
+func (w *workload) confirmChannel() (*confirmChannel, error) {
+	ch, err := w.conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+	if err := ch.Confirm(false); err != nil {
+		ch.Close()
+		return nil, err
+	}
+	return &confirmChannel{
+		Channel:  ch,
+		confirms: ch.NotifyPublish(make(chan amqp.Confirmation, 1)),
+	}, nil
+}
+
+// publish publishes a persistent message and waits for the server to confirm it.
+func (ch *confirmChannel) publish(ctx context.Context, exchange string, key string, body string) error {
+	err := ch.Publish(
+		exchange, // exchange
+		key,      // routing key
+		false,    // mandatory
+		false,    // immediate
+		amqp.Publishing{
+			ContentType:  "text/plain",
+			DeliveryMode: amqp.Persistent,
+			Body:         []byte(body),
+		},
 	)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
+	select {
+	case confirm := <-ch.confirms:
+		if !confirm.Ack {
+			return fmt.Errorf("Publishing %d was nacked by the server", confirm.DeliveryTag)
+		}
+		return nil
+	case <-time.After(receiveTimeout):
+		return fmt.Errorf("Timed out waiting for the publishing to be confirmed")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
 
-	msg := <-msgs
-	value := string(msg.Body)
+// declareTemporaryQueue declares an exclusive server-named queue bound to the exchange.
+func (ch *confirmChannel) declareTemporaryQueue(exchange string, key string) (amqp.Queue, error) {
+	queue, err := ch.QueueDeclare("", false, true, true, false, nil)
+	if err != nil {
+		return queue, err
+	}
+	return queue, ch.QueueBind(queue.Name, key, exchange, false, nil)
+}
 
-	if value != expectedValue {
-		log.Fatal(fmt.Errorf("Value %q is not the expected %q", value, expectedValue))
+// receive waits for a delivery up to the receiveTimeout.
+func receive(ctx context.Context, msgs <-chan amqp.Delivery) (amqp.Delivery, error) {
+	select {
+	case msg, ok := <-msgs:
+		if !ok {
+			return msg, fmt.Errorf("Channel closed while waiting for a delivery")
+		}
+		return msg, nil
+	case <-time.After(receiveTimeout):
+		return amqp.Delivery{}, fmt.Errorf("Timed out waiting for a delivery")
+	case <-ctx.Done():
+		return amqp.Delivery{}, ctx.Err()
 	}
+}
 
-	port, exists := os.LookupEnv("PORT")
-	if !exists {
-		port = "8080"
+func expectBody(msg amqp.Delivery, expectedValue string) error {
+	if value := string(msg.Body); value != expectedValue {
+		return fmt.Errorf("Value %q is not the expected %q", value, expectedValue)
 	}
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprintf(w, "ok")
-	})
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%s", port), nil))
+	return nil
+}
+
+func (w *workload) Close() error {
+	w.ch.Close()
+	return w.conn.Close()
+}
+
+func (w *workload) Seed(ctx context.Context, value string) error {
+	queue, err := w.declareSeedQueue(value)
+	if err != nil {
+		return err
+	}
+	return w.ch.Publish(
+		"",         // exchange
+		queue.Name, // routing key
+		false,      // mandatory
+		false,      // immediate
+		amqp.Publishing{
+			ContentType:  "text/plain",
+			DeliveryMode: amqp.Persistent,
+			Body:         []byte(value),
+		},
+	)
+}
+
+func (w *workload) Verify(ctx context.Context, value string) error {
+	queue, err := w.declareSeedQueue(value)
+	if err != nil {
+		return err
+	}
+	msg, ok, err := w.ch.Get(queue.Name, false)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("Seeded value %q not found", value)
+	}
+	// Requeue the message so the value can be verified again.
+	defer msg.Nack(false, true)
+	if seeded := string(msg.Body); seeded != value {
+		return fmt.Errorf("Seeded value %q is not the expected %q", seeded, value)
+	}
+	return nil
+}
+
+// declareSeedQueue declares the queue holding the seeded value. Each value gets its own queue, so
+// apps sharing the instance don't consume each other's value.
+func (w *workload) declareSeedQueue(value string) (amqp.Queue, error) {
+	return w.ch.QueueDeclare(
+		"mits-seed-"+value, // name
+		true,               // durable
+		false,              // delete when unused
+		false,              // exclusive
+		false,              // no-wait
+		nil,                // arguments
+	)
 }
@@ -1,8 +0,0 @@
-module github.com/SUSE/minibroker-integration-tests/assets/redisapp
-
-go 1.14
-
-require (
-	github.com/cloudfoundry-community/go-cfenv v1.18.0
-	github.com/go-redis/redis/v8 v8.0.0-beta.7
-)
@@ -18,68 +18,323 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
-	"net/http"
-	"os"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/cloudfoundry-community/go-cfenv"
 	redis "github.com/go-redis/redis/v8"
+
+	"github.com/SUSE/minibroker-integration-tests/assets/internal/app"
 )
 
+// receiveTimeout bounds the wait for a published message.
+const receiveTimeout = 10 * time.Second
+
 func main() {
-	serviceName := os.Getenv("SERVICE_NAME")
-	if serviceName == "" {
-		log.Fatal("SERVICE_NAME not set")
-	}
+	app.Main(&workload{})
+}
+
+type workload struct {
+	db       redis.UniversalClient
+	topology string
+	addr     string
+	tls      *app.TLS
+}
 
-	appEnv, err := cfenv.Current()
+// Connect detects the topology from the credentials: a sentinel master_name along with the
+// sentinels addresses selects the sentinel mode, and multiple nodes select the cluster mode. A
+// single node is asked whether it has the cluster mode enabled.
+func (w *workload) Connect(ctx context.Context, service *cfenv.Service) error {
+	opts, err := universalOptions(service.Credentials)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	redisEnv, err := appEnv.Services.WithName(serviceName)
+	host, _, err := net.SplitHostPort(opts.Addrs[0])
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-
-	uriStr := redisEnv.Credentials["uri"].(string)
-	opt, err := redis.ParseURL(uriStr)
+	w.addr = opts.Addrs[0]
+	w.tls, err = app.LoadTLS(service.Credentials, host)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	if w.tls.Enabled {
+		opts.TLSConfig = w.tls.Config
 	}
 
-	fmt.Printf("Connecting to %q\n", uriStr)
-	db := redis.NewClient(opt)
-	defer db.Close()
+	w.db = redis.NewUniversalClient(opts)
+	switch {
+	case opts.MasterName != "":
+		w.topology = "sentinel"
+	case len(opts.Addrs) > 1:
+		w.topology = "cluster"
+	default:
+		w.topology = "standalone"
+		info, err := w.db.Info(ctx, "cluster").Result()
+		if err != nil {
+			w.db.Close()
+			return err
+		}
+		if strings.Contains(info, "cluster_enabled:1") {
+			w.db.Close()
+			w.db = redis.NewClusterClient(opts.Cluster())
+			w.topology = "cluster"
+		}
+	}
+	fmt.Printf("Connected to the %s Redis at %v\n", w.topology, opts.Addrs)
+	return nil
+}
 
-	ctx := context.Background()
+// universalOptions builds the client options from the uri or host, port and password in the
+// credentials, along with the optional sentinels, master_name and nodes.
+func universalOptions(credentials map[string]interface{}) (*redis.UniversalOptions, error) {
+	opts := &redis.UniversalOptions{}
+	if uri, ok := credentials["uri"].(string); ok {
+		parsed, err := redis.ParseURL(uri)
+		if err != nil {
+			return nil, err
+		}
+		opts.Addrs = []string{parsed.Addr}
+		opts.Username = parsed.Username
+		opts.Password = parsed.Password
+		opts.DB = parsed.DB
+		opts.TLSConfig = parsed.TLSConfig
+	} else if host, ok := credentials["host"].(string); ok {
+		opts.Addrs = []string{net.JoinHostPort(host, fmt.Sprint(credentials["port"]))}
+	} else {
+		return nil, fmt.Errorf("neither URI nor host supplied")
+	}
+	if password, ok := credentials["password"].(string); ok {
+		opts.Password = password
+	}
 
-	if _, err := db.Ping(ctx).Result(); err != nil {
-		log.Fatal(err)
+	if masterName, ok := credentials["master_name"].(string); ok {
+		sentinels, err := addrs(credentials["sentinels"])
+		if err != nil {
+			return nil, fmt.Errorf("invalid sentinels: %w", err)
+		}
+		if len(sentinels) == 0 {
+			return nil, fmt.Errorf("master_name supplied without sentinels")
+		}
+		opts.MasterName = masterName
+		opts.Addrs = sentinels
+	} else if nodes, err := addrs(credentials["nodes"]); err != nil {
+		return nil, fmt.Errorf("invalid nodes: %w", err)
+	} else if len(nodes) > 0 {
+		opts.Addrs = nodes
 	}
+	return opts, nil
+}
 
-	const key = "foo"
-	const expectedValue = "bar"
+// addrs returns the host:port addresses from a list of addresses or redis URIs.
+func addrs(value interface{}) ([]string, error) {
+	if value == nil {
+		return nil, nil
+	}
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected a list, got %T", value)
+	}
+	addrs := make([]string, 0, len(list))
+	for _, item := range list {
+		addr, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a string, got %T", item)
+		}
+		if strings.Contains(addr, "://") {
+			parsed, err := redis.ParseURL(addr)
+			if err != nil {
+				return nil, err
+			}
+			addr = parsed.Addr
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
 
-	if err := db.Set(ctx, key, expectedValue, 0).Err(); err != nil {
-		log.Fatal(err)
+func (w *workload) Run(ctx context.Context) error {
+	if _, err := w.db.Ping(ctx).Result(); err != nil {
+		return err
 	}
 
-	value, err := db.Get(ctx, key).Result()
+	return app.RunChecks(ctx, []app.Check{
+		{Name: "string", Run: w.checkString},
+		{Name: "list", Run: w.checkList},
+		{Name: "hash", Run: w.checkHash},
+		{Name: "pubsub", Run: w.checkPubSub},
+		{Name: "expiry", Run: w.checkExpiry},
+		{Name: "tls", Run: w.checkTLS},
+	})
+}
+
+// checkTLS reports the TLS protocol negotiated with the first node when TLS is enabled.
+func (w *workload) checkTLS(ctx context.Context) error {
+	if !w.tls.Enabled {
+		app.SkipTLS()
+		return nil
+	}
+	state, err := app.HandshakeTLS(w.addr, w.tls.Config)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
+	app.ReportTLS(app.TLSVersionName(state.Version), w.tls)
+	return nil
+}
 
+// uniqueKey returns a key not used by any other run.
+func uniqueKey(kind string) string {
+	return "mits:" + kind + ":" + strconv.FormatInt(time.Now().UnixNano(), 10)
+}
+
+func (w *workload) checkString(ctx context.Context) error {
+	key := uniqueKey("string")
+	defer w.db.Del(ctx, key)
+	const expectedValue = "bar"
+	if err := w.db.Set(ctx, key, expectedValue, 0).Err(); err != nil {
+		return err
+	}
+	value, err := w.db.Get(ctx, key).Result()
+	if err != nil {
+		return err
+	}
 	if value != expectedValue {
-		log.Fatal(fmt.Errorf("Value %q is not the expected %q", value, expectedValue))
+		return fmt.Errorf("Value %q is not the expected %q", value, expectedValue)
 	}
+	return nil
+}
 
-	port, exists := os.LookupEnv("PORT")
-	if !exists {
-		port = "8080"
+func (w *workload) checkList(ctx context.Context) error {
+	key := uniqueKey("list")
+	defer w.db.Del(ctx, key)
+	expectedValues := []string{"a", "b", "c"}
+	if err := w.db.RPush(ctx, key, "a", "b", "c").Err(); err != nil {
+		return err
 	}
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprintf(w, "ok")
-	})
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%s", port), nil))
+	values, err := w.db.LRange(ctx, key, 0, -1).Result()
+	if err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(values, expectedValues) {
+		return fmt.Errorf("List %v is not the expected %v", values, expectedValues)
+	}
+	value, err := w.db.LPop(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+	if value != "a" {
+		return fmt.Errorf("Popped %q, expected %q", value, "a")
+	}
+	return nil
+}
+
+func (w *workload) checkHash(ctx context.Context) error {
+	key := uniqueKey("hash")
+	defer w.db.Del(ctx, key)
+	expectedValues := map[string]string{"name": "mits", "kind": "hash"}
+	if err := w.db.HSet(ctx, key, "name", "mits", "kind", "hash").Err(); err != nil {
+		return err
+	}
+	values, err := w.db.HGetAll(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(values, expectedValues) {
+		return fmt.Errorf("Hash %v is not the expected %v", values, expectedValues)
+	}
+	if err := w.db.HDel(ctx, key, "kind").Err(); err != nil {
+		return err
+	}
+	exists, err := w.db.HExists(ctx, key, "kind").Result()
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("Deleted hash field still exists")
+	}
+	return nil
+}
+
+func (w *workload) checkPubSub(ctx context.Context) error {
+	channel := uniqueKey("channel")
+	pubsub := w.db.Subscribe(ctx, channel)
+	defer pubsub.Close()
+	// Wait for the subscription to be confirmed, so the message isn't published before it.
+	if _, err := pubsub.ReceiveTimeout(ctx, receiveTimeout); err != nil {
+		return err
+	}
+
+	expectedValue := channel
+	if err := w.db.Publish(ctx, channel, expectedValue).Err(); err != nil {
+		return err
+	}
+	received, err := pubsub.ReceiveTimeout(ctx, receiveTimeout)
+	if err != nil {
+		return err
+	}
+	msg, ok := received.(*redis.Message)
+	if !ok {
+		return fmt.Errorf("Received %T, expected a message", received)
+	}
+	if msg.Payload != expectedValue {
+		return fmt.Errorf("Value %q is not the expected %q", msg.Payload, expectedValue)
+	}
+	return nil
+}
+
+func (w *workload) checkExpiry(ctx context.Context) error {
+	key := uniqueKey("expiry")
+	defer w.db.Del(ctx, key)
+	const expiration = 500 * time.Millisecond
+	if err := w.db.Set(ctx, key, "expiring", expiration).Err(); err != nil {
+		return err
+	}
+	ttl, err := w.db.PTTL(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+	if ttl <= 0 || ttl > expiration {
+		return fmt.Errorf("TTL %s is not within the expected %s", ttl, expiration)
+	}
+
+	deadline := time.Now().Add(receiveTimeout)
+	for time.Now().Before(deadline) {
+		time.Sleep(expiration)
+		err := w.db.Get(ctx, key).Err()
+		if errors.Is(err, redis.Nil) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return fmt.Errorf("Key did not expire after %s", expiration)
+}
+
+func (w *workload) Close() error {
+	return w.db.Close()
+}
+
+// seedKeyPrefix prefixes the key holding each seeded value, so apps sharing the instance don't
+// overwrite each other's value.
+const seedKeyPrefix = "mits-seed:"
+
+func (w *workload) Seed(ctx context.Context, value string) error {
+	return w.db.Set(ctx, seedKeyPrefix+value, value, 0).Err()
+}
+
+func (w *workload) Verify(ctx context.Context, value string) error {
+	seeded, err := w.db.Get(ctx, seedKeyPrefix+value).Result()
+	if err != nil {
+		return err
+	}
+	if seeded != value {
+		return fmt.Errorf("Seeded value %q is not the expected %q", seeded, value)
+	}
+	return nil
 }
@@ -6,5 +6,6 @@ require (
 	github.com/cloudfoundry-incubator/cf-test-helpers v1.0.0
 	github.com/onsi/ginkgo v1.14.0
 	github.com/onsi/gomega v1.10.1
+	github.com/xeipuuv/gojsonschema v1.2.0
 	gopkg.in/yaml.v2 v2.3.0
 )
@@ -0,0 +1,93 @@
+/*
+   Copyright 2020 SUSE
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package mits
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/onsi/gomega/gexec"
+)
+
+// AppURL returns the URL for the first route mapped to an app.
+func AppURL(appName string, stderr io.Writer, timeout time.Duration) (string, error) {
+	guid, err := cfGUID(stderr, timeout, "app", "--guid", appName)
+	if err != nil {
+		return "", fmt.Errorf("failed to get app URL: %w", err)
+	}
+
+	var routes struct {
+		Resources []struct {
+			URL string `json:"url"`
+		} `json:"resources"`
+	}
+	if err := cfCurl(stderr, timeout, &routes, "/v3/apps/"+guid+"/routes"); err != nil {
+		return "", fmt.Errorf("failed to get app URL: %w", err)
+	}
+	if len(routes.Resources) == 0 {
+		return "", fmt.Errorf("failed to get app URL: app %s has no routes", appName)
+	}
+	return "https://" + routes.Resources[0].URL, nil
+}
+
+// cfGUID runs a cf command that prints a guid and returns it.
+func cfGUID(stderr io.Writer, timeout time.Duration, args ...string) (string, error) {
+	var guidBuilder strings.Builder
+	cmd := exec.Command("cf", args...)
+	session, err := gexec.Start(cmd, &guidBuilder, stderr)
+	if err != nil {
+		return "", err
+	}
+	if exitCode := session.Wait(timeout).ExitCode(); exitCode != 0 {
+		return "", fmt.Errorf("cf %s exited with code %d", strings.Join(args, " "), exitCode)
+	}
+	return strings.TrimSpace(guidBuilder.String()), nil
+}
+
+// cfCurl runs cf curl with the given args and decodes the JSON response body into v.
+func cfCurl(stderr io.Writer, timeout time.Duration, v interface{}, args ...string) error {
+	cmd := exec.Command("cf", append([]string{"curl", "--fail"}, args...)...)
+	pipeReader, pipeWriter := io.Pipe()
+	session, err := gexec.Start(cmd, pipeWriter, stderr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		defer pipeWriter.Close()
+		session.Wait(timeout)
+	}()
+
+	decoder := json.NewDecoder(pipeReader)
+	decodeErr := decoder.Decode(v)
+	// Drain the remaining output so the session is able to exit.
+	io.Copy(ioutil.Discard, pipeReader)
+
+	// cf curl exits with code 22 if an error is reported back.
+	// On success, it is returning non-zero codes, hence the unusual check below.
+	if exitCode := session.ExitCode(); exitCode == 22 {
+		return fmt.Errorf("cf curl %s exited with code %d", strings.Join(args, " "), exitCode)
+	}
+	if decodeErr != nil {
+		return decodeErr
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+/*
+   Copyright 2020 SUSE
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package mits
+
+import (
+	"encoding/json"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gexec"
+
+	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/generator"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/workflowhelpers"
+
+	"github.com/SUSE/minibroker-integration-tests/mits/config"
+)
+
+// BindingParameters asserts that the service broker handles binding parameters according to the
+// service_binding schema declared by the plan. The binding must be created with the given name and,
+// if the plan declares the schema and the bindings are retrievable, with the given parameters. The
+// OSB API doesn't require rejecting the parameters of a plan without the schema, so the outcome is
+// only reported for such plans, and the spec is skipped when the binding is rejected.
+func BindingParameters(
+	testSetup *workflowhelpers.ReproducibleTestSuiteSetup,
+	mitsConfig *config.Config,
+	serviceBrokerName string,
+	c Case,
+	bindParams map[string]interface{},
+) {
+	timeouts := mitsConfig.Timeouts.Merge(c.TestConfig.Timeouts)
+	appName := generator.PrefixedRandomName(c.TestConfig.Class, "app")
+	serviceName := generator.PrefixedRandomName(c.TestConfig.Class, "service")
+	bindingName := generator.PrefixedRandomName(c.TestConfig.Class, "binding")
+
+	By("fetching the plan from the catalog")
+	plan, err := FetchPlan(serviceBrokerName, c.TestConfig.Class, c.TestConfig.Plan, GinkgoWriter, testSetup.ShortTimeout())
+	Expect(err).NotTo(HaveOccurred())
+	declaresBindingParams := len(plan.Schemas.ServiceBinding.Create.Parameters) > 0
+
+	defer pushAsset(testSetup, timeouts, appName, serviceName, c.Asset)()
+
+	service := NewService(serviceName, serviceBrokerName, GinkgoWriter, GinkgoWriter)
+
+	params := config.MergeParams(c.Params, c.TestConfig.Params)
+	validateProvisioningParams(testSetup, serviceBrokerName, c.TestConfig, params)
+
+	By("creating the service instance")
+	err = service.Create(c.TestConfig, params, timeouts.CFCreateService)
+	Expect(err).NotTo(HaveOccurred())
+	defer service.Destroy(testSetup.ShortTimeout())
+
+	By("waiting for the service instance to become ready")
+	err = service.WaitForCreate(timeouts.CFCreateService)
+	Expect(err).NotTo(HaveOccurred())
+
+	paramsBytes, err := json.Marshal(bindParams)
+	Expect(err).NotTo(HaveOccurred())
+
+	By("binding the service instance with parameters")
+	err = service.Bind(appName, bindingName, bindParams, testSetup.ShortTimeout())
+	if !declaresBindingParams {
+		if err != nil {
+			fmt.Fprintf(GinkgoWriter, "The %s plan declares no service_binding schema and rejected the binding parameters: %v\n", c.TestConfig.Plan, err)
+			Skip("the plan declares no service_binding schema and rejected the binding parameters")
+		}
+		fmt.Fprintf(GinkgoWriter, "The %s plan declares no service_binding schema but accepted the binding parameters\n", c.TestConfig.Plan)
+	}
+	Expect(err).NotTo(HaveOccurred())
+	defer service.Unbind(appName, testSetup.ShortTimeout())
+
+	By("asserting the binding name and parameters took effect")
+	binding, err := service.Binding(appName, testSetup.ShortTimeout())
+	Expect(err).NotTo(HaveOccurred())
+	Expect(binding.Name).To(Equal(bindingName))
+	if declaresBindingParams && plan.Offering.BindingsRetrievable {
+		var expectedParams map[string]interface{}
+		err = json.Unmarshal(paramsBytes, &expectedParams)
+		Expect(err).NotTo(HaveOccurred())
+		actualParams, err := service.BindingParameters(binding, testSetup.ShortTimeout())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(actualParams).To(Equal(expectedParams))
+	}
+
+	defer setupSecurityGroup(testSetup, mitsConfig.SecurityGroups, c.TestConfig, service)()
+
+	defer func() {
+		cf.Cf("logs", appName, "--recent").Wait(testSetup.ShortTimeout())
+	}()
+	By("starting the app")
+	Expect(
+		cf.Cf("start", appName).
+			Wait(timeouts.CFStart),
+	).To(Exit(0))
+}
@@ -19,12 +19,16 @@ package mits
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
+	"net/url"
 	"os"
-	"strconv"
+	"time"
 
 	"github.com/SUSE/minibroker-integration-tests/mits/config"
+	"github.com/SUSE/minibroker-integration-tests/mits/schema"
+	"github.com/SUSE/minibroker-integration-tests/mits/securitygroup"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	. "github.com/onsi/gomega/gexec"
@@ -34,71 +38,261 @@ import (
 	"github.com/cloudfoundry-incubator/cf-test-helpers/workflowhelpers"
 )
 
+// assetsPath is the path to the Go module holding all the asset apps.
+const assetsPath = "assets"
+
+// Case is a service class along with the asset app and the provisioning params used to test it.
+type Case struct {
+	TestConfig config.TestConfig
+	Asset      string
+	Params     map[string]interface{}
+}
+
 // SimpleAppAndService asserts that a service can be bound to an app. Apps are expected to perform
 // their own assertion on the service. Apps MUST only successfully start after it finished all
-// assertions.
+// assertions. The asset is the name of the app package under assetsPath. The params and timeouts
+// from the testConfig are merged over the given params and the global timeouts. When the soak mode
+// is enabled, the app workload is exercised periodically before the app and service are destroyed.
 func SimpleAppAndService(
 	testSetup *workflowhelpers.ReproducibleTestSuiteSetup,
+	mitsConfig *config.Config,
 	testConfig config.TestConfig,
-	timeouts config.Timeouts,
 	serviceBrokerName string,
-	appPath string,
+	asset string,
 	params map[string]interface{},
 ) {
-	orgName := testSetup.TestSpace.OrganizationName()
-	spaceName := testSetup.TestSpace.SpaceName()
+	timeouts := mitsConfig.Timeouts.Merge(testConfig.Timeouts)
+	params = config.MergeParams(params, testConfig.Params)
 	appName := generator.PrefixedRandomName(testConfig.Class, "app")
 	serviceName := generator.PrefixedRandomName(testConfig.Class, "service")
-	securityGroupName := generator.PrefixedRandomName(testConfig.Class, "security-group")
 
-	By("pushing the test app without starting")
+	defer pushAsset(testSetup, timeouts, appName, serviceName, asset)()
+
+	service := NewService(serviceName, serviceBrokerName, GinkgoWriter, GinkgoWriter)
+
+	validateProvisioningParams(testSetup, serviceBrokerName, testConfig, params)
+
+	By("creating the service instance")
+	err := service.Create(testConfig, params, timeouts.CFCreateService)
+	Expect(err).NotTo(HaveOccurred())
+	defer service.Destroy(testSetup.ShortTimeout())
+
+	By("waiting for the service instance to become ready")
+	err = service.WaitForCreate(timeouts.CFCreateService)
+	Expect(err).NotTo(HaveOccurred())
+
+	By("binding the service instance to the app")
+	err = service.Bind(appName, "", testConfig.BindParams, testSetup.ShortTimeout())
+	Expect(err).NotTo(HaveOccurred())
+	defer service.Unbind(appName, testSetup.ShortTimeout())
+
+	defer setupSecurityGroup(testSetup, mitsConfig.SecurityGroups, testConfig, service)()
+
+	defer func() {
+		cf.Cf("logs", appName, "--recent").Wait(testSetup.ShortTimeout())
+	}()
+	By("starting the app")
 	Expect(
-		cf.Cf("push", appName, "--no-start", "-p", appPath).
-			Wait(timeouts.CFPush),
+		cf.Cf("start", appName).
+			Wait(timeouts.CFStart),
 	).To(Exit(0))
-	defer func() {
+
+	// The apps created from the droplet cache get their route from createFromDroplet, which the soak
+	// and the multiple apps scenarios rely on.
+	By("asserting the app has a route")
+	appURL, err := AppURL(appName, GinkgoWriter, testSetup.ShortTimeout())
+	Expect(err).NotTo(HaveOccurred())
+
+	if mitsConfig.Soak.Enabled {
+		By("soaking the app and service")
+		controller, err := NewSoakController(appURL, mitsConfig.Soak, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		report := controller.Run()
+		fmt.Fprintf(GinkgoWriter, "Soak report for %s: %s\n", appName, report)
+		Expect(report.Availability()).To(BeNumerically(">=", mitsConfig.Soak.MinAvailability), "soak errors: %v", report.Errors)
+		Expect(report.Restarts).To(BeZero())
+	}
+}
+
+// pushAsset pushes the asset app without starting it, pointing it to the service named
+// serviceName, if set. When the droplet cache holds the asset, the app is created from a copy of
+// its droplet instead of being staged again. It returns a function that deletes the app.
+func pushAsset(
+	testSetup *workflowhelpers.ReproducibleTestSuiteSetup,
+	timeouts config.Timeouts,
+	appName string,
+	serviceName string,
+	asset string,
+) func() {
+	return pushAssetApp(testSetup, timeouts, appName, serviceName, asset, true)
+}
+
+// pushAssetPackage is like pushAsset, but always pushes the asset package, bypassing the droplet
+// cache. The apps created from a droplet copy have no package, so the scenarios restaging their
+// app must use it.
+func pushAssetPackage(
+	testSetup *workflowhelpers.ReproducibleTestSuiteSetup,
+	timeouts config.Timeouts,
+	appName string,
+	serviceName string,
+	asset string,
+) func() {
+	return pushAssetApp(testSetup, timeouts, appName, serviceName, asset, false)
+}
+
+func pushAssetApp(
+	testSetup *workflowhelpers.ReproducibleTestSuiteSetup,
+	timeouts config.Timeouts,
+	appName string,
+	serviceName string,
+	asset string,
+	useDropletCache bool,
+) func() {
+	deleteApp := func() {
 		cf.Cf("delete", appName, "-r", "-f").Wait(testSetup.ShortTimeout())
-	}()
+	}
+	if useDropletCache && dropletCache != nil && dropletCache.Droplets[asset] != "" {
+		By("creating the test app from the " + asset + " droplet staged for the suite")
+		defer cleanupOnFailure(deleteApp)
+		createFromDroplet(testSetup, appName, dropletCache.Droplets[asset], "./bin/"+asset)
+	} else {
+		By("pushing the test app without starting")
+		Expect(
+			cf.Cf("push", appName, "--no-start", "-p", assetsPath, "-c", "./bin/"+asset).
+				Wait(timeouts.CFPush),
+		).To(Exit(0))
+		defer cleanupOnFailure(deleteApp)
+		By("setting the GO_INSTALL_PACKAGE_SPEC environment variable in the app")
+		Expect(
+			cf.Cf("set-env", appName, "GO_INSTALL_PACKAGE_SPEC", "./"+asset).
+				Wait(testSetup.ShortTimeout()),
+		).To(Exit(0))
+	}
+	if serviceName == "" {
+		return deleteApp
+	}
 	By("setting the SERVICE_NAME environment variable in the app")
 	Expect(
 		cf.Cf("set-env", appName, "SERVICE_NAME", serviceName).
 			Wait(testSetup.ShortTimeout()),
 	).To(Exit(0))
+	return deleteApp
+}
 
+// provisionAndBind creates the service instance with the params, once validated against the plan
+// schema. Each of the appNames is then pushed from the asset with push, either pushAsset or
+// pushAssetPackage, and bound to the service instance without being started, and the security
+// groups are set up for them in the test space. It returns the service instance and a function that
+// prints the recent logs of the apps and reverts it all.
+func provisionAndBind(
+	testSetup *workflowhelpers.ReproducibleTestSuiteSetup,
+	mitsConfig *config.Config,
+	serviceBrokerName string,
+	testConfig config.TestConfig,
+	params map[string]interface{},
+	asset string,
+	push func(*workflowhelpers.ReproducibleTestSuiteSetup, config.Timeouts, string, string, string) func(),
+	appNames ...string,
+) (*Service, func()) {
+	timeouts := mitsConfig.Timeouts.Merge(testConfig.Timeouts)
+	serviceName := generator.PrefixedRandomName(testConfig.Class, "service")
 	service := NewService(serviceName, serviceBrokerName, GinkgoWriter, GinkgoWriter)
 
+	var cleanups []func()
+	cleanup := func() {
+		for i := len(cleanups) - 1; i >= 0; i-- {
+			cleanups[i]()
+		}
+	}
+	defer cleanupOnFailure(cleanup)
+
+	validateProvisioningParams(testSetup, serviceBrokerName, testConfig, params)
+
 	By("creating the service instance")
 	err := service.Create(testConfig, params, timeouts.CFCreateService)
 	Expect(err).NotTo(HaveOccurred())
-	defer service.Destroy(testSetup.ShortTimeout())
+	cleanups = append(cleanups, func() {
+		service.Destroy(testSetup.ShortTimeout())
+	})
 
 	By("waiting for the service instance to become ready")
 	err = service.WaitForCreate(timeouts.CFCreateService)
 	Expect(err).NotTo(HaveOccurred())
 
-	By("binding the service instance to the app")
-	err = service.Bind(appName, testSetup.ShortTimeout())
-	Expect(err).NotTo(HaveOccurred())
-	defer service.Unbind(appName, testSetup.ShortTimeout())
+	if len(appNames) > 0 {
+		cleanups = append(cleanups, setupSecurityGroup(testSetup, mitsConfig.SecurityGroups, testConfig, service))
+	}
+	for _, appName := range appNames {
+		appName := appName
+		cleanups = append(cleanups, push(testSetup, timeouts, appName, serviceName, asset))
+
+		By("binding the service instance to the app " + appName)
+		err := service.Bind(appName, "", testConfig.BindParams, testSetup.ShortTimeout())
+		Expect(err).NotTo(HaveOccurred())
+		cleanups = append(cleanups, func() {
+			service.Unbind(appName, testSetup.ShortTimeout())
+		}, func() {
+			cf.Cf("logs", appName, "--recent").Wait(testSetup.ShortTimeout())
+		})
+	}
+	return service, cleanup
+}
+
+// setupSecurityGroup allows the apps in the test space to reach the service instance according to
+// the security groups mode. Only the per-instance mode requires any work for each service instance.
+// It returns a function that reverts the setup.
+func setupSecurityGroup(
+	testSetup *workflowhelpers.ReproducibleTestSuiteSetup,
+	securityGroups config.SecurityGroups,
+	testConfig config.TestConfig,
+	service *Service,
+) func() {
+	return setupSpaceSecurityGroup(testSetup, securityGroups, testConfig, service, testSetup.TestSpace.SpaceName())
+}
+
+// setupSpaceSecurityGroup is like setupSecurityGroup for the apps in any space of the test
+// organization. The shared security group is only bound to the test space for the whole suite, so
+// it is bound to any other space here.
+func setupSpaceSecurityGroup(
+	testSetup *workflowhelpers.ReproducibleTestSuiteSetup,
+	securityGroups config.SecurityGroups,
+	testConfig config.TestConfig,
+	service *Service,
+	spaceName string,
+) func() {
+	switch securityGroups.Mode {
+	case config.SecurityGroupsPerInstance:
+		return createSecurityGroup(testSetup, testConfig, service, spaceName)
+	case config.SecurityGroupsShared:
+		if spaceName == testSetup.TestSpace.SpaceName() {
+			return func() {}
+		}
+		return bindSecurityGroup(testSetup, securityGroups.SharedName, spaceName)
+	default:
+		return func() {}
+	}
+}
+
+// createSecurityGroup creates and binds a security-group allowing the apps in the space to reach
+// the service instance. It returns a function that unbinds and deletes the security-group.
+func createSecurityGroup(
+	testSetup *workflowhelpers.ReproducibleTestSuiteSetup,
+	testConfig config.TestConfig,
+	service *Service,
+	spaceName string,
+) func() {
+	securityGroupName := generator.PrefixedRandomName(testConfig.Class, "security-group")
 
 	By("creating and binding a security-group for the service instance")
 	credentials, err := service.Credentials(testSetup.ShortTimeout())
 	Expect(err).NotTo(HaveOccurred())
 
-	host := credentials["host"].(string)
-	port := strconv.Itoa(int(credentials["port"].(float64)))
-	hostIP, err := net.LookupIP(host)
+	endpoints, err := securitygroup.Endpoints(credentials)
 	Expect(err).NotTo(HaveOccurred())
-
-	securityGroup := []map[string]string{
-		{
-			"protocol":    "tcp",
-			"destination": fmt.Sprintf("%s/32", hostIP[0]),
-			"ports":       port,
-			"description": fmt.Sprintf("Allow traffic to %s", serviceName),
-		},
-	}
-	securityGroupFile, err := ioutil.TempFile("", fmt.Sprintf("%s_security_group.json", serviceName))
+	securityGroup, err := securitygroup.NewBuilder(net.LookupIP).
+		Rules(endpoints, fmt.Sprintf("Allow traffic to %s", service.name))
+	Expect(err).NotTo(HaveOccurred())
+	securityGroupFile, err := ioutil.TempFile("", fmt.Sprintf("%s_security_group.json", service.name))
 	Expect(err).NotTo(HaveOccurred())
 	defer os.Remove(securityGroupFile.Name())
 	encoder := json.NewEncoder(securityGroupFile)
@@ -112,35 +306,125 @@ func SimpleAppAndService(
 				Wait(testSetup.ShortTimeout()),
 		).To(Exit(0))
 	})
-	defer func() {
+	deleteSecurityGroup := func() {
 		workflowhelpers.AsUser(testSetup.AdminUserContext(), testSetup.ShortTimeout(), func() {
 			Expect(
 				cf.Cf("delete-security-group", securityGroupName, "-f").
 					Wait(testSetup.ShortTimeout()),
 			).To(Exit(0))
 		})
-	}()
+	}
+	defer cleanupOnFailure(deleteSecurityGroup)
+	unbindSecurityGroup := bindSecurityGroup(testSetup, securityGroupName, spaceName)
+	return func() {
+		defer deleteSecurityGroup()
+		unbindSecurityGroup()
+	}
+}
+
+// bindSecurityGroup binds an existing security-group to the space in the test organization. It
+// returns a function that unbinds the security-group.
+func bindSecurityGroup(
+	testSetup *workflowhelpers.ReproducibleTestSuiteSetup,
+	securityGroupName string,
+	spaceName string,
+) func() {
+	orgName := testSetup.TestSpace.OrganizationName()
 	workflowhelpers.AsUser(testSetup.AdminUserContext(), testSetup.ShortTimeout(), func() {
 		Expect(
 			cf.Cf("bind-security-group", securityGroupName, orgName, "--space", spaceName, "--lifecycle", "running").
 				Wait(testSetup.ShortTimeout()),
 		).To(Exit(0))
 	})
-	defer func() {
+	return func() {
 		workflowhelpers.AsUser(testSetup.AdminUserContext(), testSetup.ShortTimeout(), func() {
 			Expect(
 				cf.Cf("unbind-security-group", securityGroupName, orgName, spaceName, "--lifecycle", "running").
 					Wait(testSetup.ShortTimeout()),
 			).To(Exit(0))
 		})
-	}()
+	}
+}
 
-	defer func() {
-		cf.Cf("logs", appName, "--recent").Wait(testSetup.ShortTimeout())
-	}()
-	By("starting the app")
-	Expect(
-		cf.Cf("start", appName).
-			Wait(timeouts.CFStart),
-	).To(Exit(0))
+// BindSharedSecurityGroup binds the pre-existing security group to the test space for the running
+// apps as the admin user, unless it is already bound, and returns a function that reverts it. A
+// binding made outside of MITS is left in place.
+func BindSharedSecurityGroup(
+	testSetup *workflowhelpers.ReproducibleTestSuiteSetup,
+	securityGroupName string,
+) func() {
+	spaceName := testSetup.TestSpace.SpaceName()
+	var bound bool
+	workflowhelpers.AsUser(testSetup.AdminUserContext(), testSetup.ShortTimeout(), func() {
+		var err error
+		bound, err = securityGroupBound(securityGroupName, spaceName, GinkgoWriter, testSetup.ShortTimeout())
+		Expect(err).NotTo(HaveOccurred())
+	})
+	if bound {
+		return func() {}
+	}
+	By("binding the shared security group to the test space")
+	return bindSecurityGroup(testSetup, securityGroupName, spaceName)
+}
+
+// securityGroupBound returns whether the security group is bound to the space in the targeted
+// organization for the running apps.
+func securityGroupBound(securityGroupName string, spaceName string, stderr io.Writer, timeout time.Duration) (bool, error) {
+	spaceGUID, err := cfGUID(stderr, timeout, "space", "--guid", spaceName)
+	if err != nil {
+		return false, fmt.Errorf("failed to check the security group binding: %w", err)
+	}
+	query := url.Values{}
+	query.Set("names", securityGroupName)
+	var securityGroups struct {
+		Resources []struct {
+			Relationships struct {
+				RunningSpaces struct {
+					Data []struct {
+						GUID string `json:"guid"`
+					} `json:"data"`
+				} `json:"running_spaces"`
+			} `json:"relationships"`
+		} `json:"resources"`
+	}
+	if err := cfCurl(stderr, timeout, &securityGroups, "/v3/security_groups?"+query.Encode()); err != nil {
+		return false, fmt.Errorf("failed to check the security group binding: %w", err)
+	}
+	if len(securityGroups.Resources) == 0 {
+		return false, fmt.Errorf("failed to check the security group binding: security group %s not found", securityGroupName)
+	}
+	for _, space := range securityGroups.Resources[0].Relationships.RunningSpaces.Data {
+		if space.GUID == spaceGUID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// validateProvisioningParams asserts that the params match the create schema advertised by the
+// plan before provisioning, so a test bug can be told apart from a service broker bug. Plans that
+// don't advertise a schema accept any params.
+func validateProvisioningParams(
+	testSetup *workflowhelpers.ReproducibleTestSuiteSetup,
+	serviceBrokerName string,
+	testConfig config.TestConfig,
+	params map[string]interface{},
+) {
+	if params == nil {
+		return
+	}
+	By("validating the provisioning params against the plan schema")
+	plan, err := FetchPlan(serviceBrokerName, testConfig.Class, testConfig.Plan, GinkgoWriter, testSetup.ShortTimeout())
+	Expect(err).NotTo(HaveOccurred())
+	err = schema.ValidateParams(plan.Schemas.ServiceInstance.Create.Parameters, params)
+	Expect(err).NotTo(HaveOccurred(), "the test provisioning params are invalid for the %s plan %s", testConfig.Class, testConfig.Plan)
+}
+
+// cleanupOnFailure runs cleanup when deferred from a helper that fails an assertion before it can
+// hand its cleanup function over to the caller.
+func cleanupOnFailure(cleanup func()) {
+	if r := recover(); r != nil {
+		cleanup()
+		panic(r)
+	}
 }
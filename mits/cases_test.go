@@ -0,0 +1,150 @@
+/*
+   Copyright 2020 SUSE
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package mits_test
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+
+	"github.com/SUSE/minibroker-integration-tests/mits"
+	"github.com/SUSE/minibroker-integration-tests/mits/config"
+)
+
+// serviceTest ties a test config to the asset app and the extra provisioning params used to test
+// it. The config and params are functions since they are only known once the suite is set up.
+type serviceTest struct {
+	name   string
+	config func() config.TestConfig
+	asset  string
+	params func() map[string]interface{}
+}
+
+// serviceTests are used by the scenarios exercised for every service class.
+var serviceTests = []serviceTest{
+	{
+		name:   "MariaDB",
+		config: func() config.TestConfig { return mitsConfig.Tests.MariaDB },
+		asset:  "mysqlapp",
+		params: mariadbParams,
+	},
+	{
+		name:   "MongoDB",
+		config: func() config.TestConfig { return mitsConfig.Tests.MongoDB },
+		asset:  "mongodbapp",
+		params: mongodbParams,
+	},
+	{
+		name:   "MySQL",
+		config: func() config.TestConfig { return mitsConfig.Tests.MySQL },
+		asset:  "mysqlapp",
+		params: mysqlParams,
+	},
+	{
+		name:   "PostgreSQL",
+		config: func() config.TestConfig { return mitsConfig.Tests.PostgreSQL },
+		asset:  "postgresqlapp",
+		params: postgresqlParams,
+	},
+	{
+		name:   "RabbitMQ",
+		config: func() config.TestConfig { return mitsConfig.Tests.RabbitMQ },
+		asset:  "rabbitmqapp",
+		params: rabbitmqParams,
+	},
+	{
+		name:   "Redis",
+		config: func() config.TestConfig { return mitsConfig.Tests.Redis },
+		asset:  "redisapp",
+		params: redisParams,
+	},
+}
+
+// extraServiceTests returns a service test for each extra test, without any extra provisioning
+// params since the extra tests are described by their config entries alone.
+func extraServiceTests(extraTests []config.ExtraTest) []serviceTest {
+	tests := make([]serviceTest, 0, len(extraTests))
+	for _, extraTest := range extraTests {
+		extraTest := extraTest
+		tests = append(tests, serviceTest{
+			name:   extraTest.Name,
+			config: func() config.TestConfig { return extraTest.TestConfig },
+			asset:  extraTest.Asset,
+			params: func() map[string]interface{} { return nil },
+		})
+	}
+	return tests
+}
+
+// mitsCase returns the case for the service test. The extra provisioning params are left out when
+// overrideParams are set.
+func (t serviceTest) mitsCase() mits.Case {
+	c := mits.Case{
+		TestConfig: t.config(),
+		Asset:      t.asset,
+	}
+	if !mitsConfig.Minibroker.Provisioning.OverrideParams.Enabled {
+		c.Params = t.params()
+	}
+	return c
+}
+
+// skipUnlessEnabled skips the current spec if the service test is disabled or if only the upgrade
+// scenario should run.
+func (t serviceTest) skipUnlessEnabled() {
+	if !t.config().Enabled {
+		Skip("All " + t.name + " tests are disabled")
+	}
+	if mitsConfig.Upgrade.Enabled {
+		Skip("Only the upgrade scenario runs when enabled")
+	}
+}
+
+// scenarios are the scenarios declared by describeScenario, described by describeScenarios.
+var scenarios []func(serviceTests []serviceTest)
+
+// describeScenario declares a scenario run for every service test, with the spec text formatted
+// with the service test name. Each spec is skipped unless both the scenario and the service test
+// are enabled.
+func describeScenario(name string, enabled func(config.Scenarios) bool, text string, body func(serviceTest)) bool {
+	scenarios = append(scenarios, func(serviceTests []serviceTest) {
+		Describe(name, func() {
+			for _, serviceTest := range serviceTests {
+				serviceTest := serviceTest
+
+				It(fmt.Sprintf(text, serviceTest.name), func() {
+					if !enabled(mitsConfig.Scenarios) {
+						Skip("The " + name + " scenario is disabled")
+					}
+					serviceTest.skipUnlessEnabled()
+
+					body(serviceTest)
+				})
+			}
+		})
+	})
+	return true
+}
+
+// describeScenarios describes the declared scenarios for the built-in and the extra tests. It must
+// be called before the specs run, once the config is loaded.
+func describeScenarios() {
+	tests := append(append([]serviceTest{}, serviceTests...), extraServiceTests(mitsConfig.ExtraTests)...)
+	for _, describe := range scenarios {
+		describe(tests)
+	}
+}
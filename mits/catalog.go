@@ -0,0 +1,164 @@
+/*
+   Copyright 2020 SUSE
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package mits
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+)
+
+// Plan is a service plan from the CF catalog, along with its service offering.
+type Plan struct {
+	GUID     string
+	Name     string
+	Schemas  PlanSchemas
+	Offering Offering
+}
+
+// PlanSchemas are the JSON Schemas a plan declares for its parameters.
+type PlanSchemas struct {
+	ServiceInstance struct {
+		Create struct {
+			Parameters map[string]interface{} `json:"parameters"`
+		} `json:"create"`
+		Update struct {
+			Parameters map[string]interface{} `json:"parameters"`
+		} `json:"update"`
+	} `json:"service_instance"`
+	ServiceBinding struct {
+		Create struct {
+			Parameters map[string]interface{} `json:"parameters"`
+		} `json:"create"`
+	} `json:"service_binding"`
+}
+
+// Offering is a service offering from the CF catalog.
+type Offering struct {
+	GUID                string
+	Name                string
+	Shareable           bool
+	BindingsRetrievable bool
+	Tags                []string
+}
+
+// FetchPlan fetches a plan by class and name from the plans registered by the service broker.
+func FetchPlan(
+	serviceBrokerName string,
+	class string,
+	planName string,
+	stderr io.Writer,
+	timeout time.Duration,
+) (*Plan, error) {
+	query := url.Values{}
+	query.Set("names", planName)
+	query.Set("service_offering_names", class)
+	plans, err := FetchPlans(serviceBrokerName, query, stderr, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch plan: %w", err)
+	}
+	if len(plans) != 1 {
+		return nil, fmt.Errorf("failed to fetch plan: found %d plans named %s for %s", len(plans), planName, class)
+	}
+	return &plans[0], nil
+}
+
+// FetchPlans fetches all the plans registered by the service broker, further filtered by the
+// optional query, using the CF v3 API.
+func FetchPlans(
+	serviceBrokerName string,
+	query url.Values,
+	stderr io.Writer,
+	timeout time.Duration,
+) ([]Plan, error) {
+	values := url.Values{}
+	for key, value := range query {
+		values[key] = value
+	}
+	values.Set("service_broker_names", serviceBrokerName)
+	values.Set("include", "service_offering")
+	path := "/v3/service_plans?" + values.Encode()
+
+	var plans []Plan
+	for path != "" {
+		var page struct {
+			Pagination struct {
+				Next *struct {
+					Href string `json:"href"`
+				} `json:"next"`
+			} `json:"pagination"`
+			Resources []struct {
+				GUID          string      `json:"guid"`
+				Name          string      `json:"name"`
+				Schemas       PlanSchemas `json:"schemas"`
+				Relationships struct {
+					ServiceOffering struct {
+						Data struct {
+							GUID string `json:"guid"`
+						} `json:"data"`
+					} `json:"service_offering"`
+				} `json:"relationships"`
+			} `json:"resources"`
+			Included struct {
+				ServiceOfferings []struct {
+					GUID          string   `json:"guid"`
+					Name          string   `json:"name"`
+					Shareable     bool     `json:"shareable"`
+					Tags          []string `json:"tags"`
+					BrokerCatalog struct {
+						Features struct {
+							BindingsRetrievable bool `json:"bindings_retrievable"`
+						} `json:"features"`
+					} `json:"broker_catalog"`
+				} `json:"service_offerings"`
+			} `json:"included"`
+		}
+		if err := cfCurl(stderr, timeout, &page, path); err != nil {
+			return nil, fmt.Errorf("failed to fetch plans: %w", err)
+		}
+
+		offerings := make(map[string]Offering)
+		for _, offering := range page.Included.ServiceOfferings {
+			offerings[offering.GUID] = Offering{
+				GUID:                offering.GUID,
+				Name:                offering.Name,
+				Shareable:           offering.Shareable,
+				BindingsRetrievable: offering.BrokerCatalog.Features.BindingsRetrievable,
+				Tags:                offering.Tags,
+			}
+		}
+		for _, resource := range page.Resources {
+			plans = append(plans, Plan{
+				GUID:     resource.GUID,
+				Name:     resource.Name,
+				Schemas:  resource.Schemas,
+				Offering: offerings[resource.Relationships.ServiceOffering.Data.GUID],
+			})
+		}
+
+		path = ""
+		if page.Pagination.Next != nil {
+			next, err := url.Parse(page.Pagination.Next.Href)
+			if err != nil {
+				return nil, fmt.Errorf("failed to fetch plans: %w", err)
+			}
+			path = next.RequestURI()
+		}
+	}
+	return plans, nil
+}
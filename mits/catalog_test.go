@@ -0,0 +1,68 @@
+/*
+   Copyright 2020 SUSE
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package mits_test
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry-incubator/cf-test-helpers/workflowhelpers"
+
+	"github.com/SUSE/minibroker-integration-tests/mits"
+	"github.com/SUSE/minibroker-integration-tests/mits/schema"
+)
+
+var _ = Describe("Catalog", func() {
+	It("should advertise well-formed parameters schemas for every plan", func() {
+		if mitsConfig.Upgrade.Enabled {
+			Skip("the upgrade compatibility scenario is enabled")
+		}
+
+		var plans []mits.Plan
+		fetchPlans := func() {
+			var err error
+			plans, err = mits.FetchPlans(serviceBrokerName, nil, GinkgoWriter, testSetup.ShortTimeout())
+			Expect(err).NotTo(HaveOccurred())
+		}
+		// Only the admin user can see the plans without service access enabled.
+		if hasAdminUser() {
+			workflowhelpers.AsUser(testSetup.AdminUserContext(), testSetup.ShortTimeout(), fetchPlans)
+		} else {
+			fetchPlans()
+		}
+		Expect(plans).NotTo(BeEmpty())
+
+		// Not every chart provides a schema, so the plans without one are only reported.
+		for _, plan := range plans {
+			By("validating the schemas of the " + plan.Offering.Name + " plan " + plan.Name)
+			create := plan.Schemas.ServiceInstance.Create.Parameters
+			if len(create) == 0 {
+				fmt.Fprintf(GinkgoWriter, "The %s plan %s doesn't advertise a service_instance.create schema\n", plan.Offering.Name, plan.Name)
+			} else {
+				Expect(schema.Validate(create)).To(Succeed())
+			}
+			if update := plan.Schemas.ServiceInstance.Update.Parameters; len(update) > 0 {
+				Expect(schema.Validate(update)).To(Succeed())
+			}
+			if binding := plan.Schemas.ServiceBinding.Create.Parameters; len(binding) > 0 {
+				Expect(schema.Validate(binding)).To(Succeed())
+			}
+		}
+	})
+})
@@ -19,12 +19,14 @@ package config
 import (
 	"fmt"
 	"os"
+	"reflect"
 	"time"
 
 	"gopkg.in/yaml.v2"
 )
 
-// Load loads a configuration file from configPath.
+// Load loads a configuration file from configPath. Any field can be overridden from the
+// environment, as described by ApplyEnv, and the resulting configuration is validated.
 func Load(configPath string) (*Config, error) {
 	configFile, err := os.Open(configPath)
 	if err != nil {
@@ -37,6 +39,22 @@ func Load(configPath string) (*Config, error) {
 	if err := decoder.Decode(&config); err != nil {
 		return nil, fmt.Errorf("failed to load config: %w", err)
 	}
+	if err := ApplyEnv(&config, os.LookupEnv); err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	if config.SecurityGroups.Mode == "" {
+		config.SecurityGroups.Mode = SecurityGroupsPerInstance
+	}
+	tests := reflect.ValueOf(&config.Tests).Elem()
+	for i := 0; i < tests.NumField(); i++ {
+		tests.Field(i).Addr().Interface().(*TestConfig).normalize()
+	}
+	for i := range config.ExtraTests {
+		config.ExtraTests[i].TestConfig.normalize()
+	}
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
 	return &config, nil
 }
 
@@ -50,6 +68,7 @@ type Config struct {
 			Username string `yaml:"username"`
 			Password string `yaml:"password"`
 		} `yaml:"admin"`
+		SpaceDeveloper SpaceDeveloper `yaml:"space_developer"`
 	} `yaml:"cf"`
 
 	Minibroker struct {
@@ -72,7 +91,19 @@ type Config struct {
 		Redis      TestConfig `yaml:"redis"`
 	} `yaml:"tests"`
 
+	ExtraTests []ExtraTest `yaml:"extra_tests"`
+
+	Scenarios Scenarios `yaml:"scenarios"`
+
+	SecurityGroups SecurityGroups `yaml:"security_groups"`
+
 	Timeouts Timeouts `yaml:"timeouts"`
+
+	Soak Soak `yaml:"soak"`
+
+	Upgrade Upgrade `yaml:"upgrade"`
+
+	Probe Probe `yaml:"probe"`
 }
 
 // TestConfig represents the configuration for an individual test.
@@ -80,11 +111,203 @@ type TestConfig struct {
 	Enabled bool   `yaml:"enabled"`
 	Class   string `yaml:"class"`
 	Plan    string `yaml:"plan"`
+	// Params are merged over the provisioning parameters set by the test.
+	Params map[string]interface{} `yaml:"params"`
+	// BindParams are the parameters used when binding the service instance.
+	BindParams map[string]interface{} `yaml:"bind_params"`
+	// TLSParams are the provisioning parameters enabling TLS, merged over Params by the TLS test. The
+	// TLS test is skipped when they are not set, since not every chart supports TLS.
+	TLSParams map[string]interface{} `yaml:"tls_params"`
+	// PerBindingCredentials asserts that the service broker issues distinct credentials to each
+	// binding of the same service instance.
+	PerBindingCredentials bool `yaml:"per_binding_credentials"`
+	// Timeouts override the global timeouts for the test. Unset timeouts are not overridden.
+	Timeouts Timeouts `yaml:"timeouts"`
 }
 
+// normalize converts the params decoded by yaml.v2 so they can be encoded as JSON.
+func (testConfig *TestConfig) normalize() {
+	testConfig.Params = stringKeys(testConfig.Params)
+	testConfig.BindParams = stringKeys(testConfig.BindParams)
+	testConfig.TLSParams = stringKeys(testConfig.TLSParams)
+}
+
+// ExtraTest is a test for a service class not built into MITS. Its specs are generated from the
+// config entry alone, using an asset app that follows the asset app contract.
+type ExtraTest struct {
+	// Name identifies the test in the specs.
+	Name string `yaml:"name"`
+	// Asset is the name of the asset app package.
+	Asset      string `yaml:"asset"`
+	TestConfig `yaml:",inline"`
+}
+
+// TestConfigs returns the configuration of every test, the built-in ones followed by the extra
+// ones.
+func (config *Config) TestConfigs() []TestConfig {
+	tests := reflect.ValueOf(config.Tests)
+	testConfigs := make([]TestConfig, 0, tests.NumField()+len(config.ExtraTests))
+	for i := 0; i < tests.NumField(); i++ {
+		testConfigs = append(testConfigs, tests.Field(i).Interface().(TestConfig))
+	}
+	for _, extraTest := range config.ExtraTests {
+		testConfigs = append(testConfigs, extraTest.TestConfig)
+	}
+	return testConfigs
+}
+
+// Scenarios enables the scenarios run for every enabled test, on top of the basic app and service
+// scenario run by each test.
+type Scenarios struct {
+	Bindings     bool `yaml:"bindings"`
+	Lookup       bool `yaml:"lookup"`
+	MultipleApps bool `yaml:"multiple_apps"`
+	Rename       bool `yaml:"rename"`
+	Revocation   bool `yaml:"revocation"`
+	ServiceKeys  bool `yaml:"service_keys"`
+	// Sharing requires the admin user to create the second space.
+	Sharing bool `yaml:"sharing"`
+	Tasks   bool `yaml:"tasks"`
+	// TLS requires the override params to be disabled, since they would replace the tls_params.
+	TLS bool `yaml:"tls"`
+}
+
+// SpaceDeveloper configures the space developer mode, in which the tests run with the restricted
+// credentials of an existing user in an existing space.
+type SpaceDeveloper struct {
+	Enabled      bool   `yaml:"enabled"`
+	Username     string `yaml:"username"`
+	Password     string `yaml:"password"`
+	Organization string `yaml:"organization"`
+	Space        string `yaml:"space"`
+}
+
+// SecurityGroups configures how the apps are allowed to reach the service instances.
+type SecurityGroups struct {
+	Mode SecurityGroupsMode `yaml:"mode"`
+	// SharedName is the name of the pre-existing security group used by the shared mode.
+	SharedName string `yaml:"shared_name"`
+}
+
+// SecurityGroupsMode selects how security groups are handled.
+type SecurityGroupsMode string
+
+const (
+	// SecurityGroupsPerInstance creates and binds a security group for each service instance.
+	// It requires the admin user.
+	SecurityGroupsPerInstance SecurityGroupsMode = "per_instance"
+	// SecurityGroupsShared binds the pre-existing security group named by SharedName to the test
+	// space once for the whole suite, unless it is already bound. It requires the admin user.
+	SecurityGroupsShared SecurityGroupsMode = "shared"
+	// SecurityGroupsNone doesn't handle security groups, for environments where the egress traffic
+	// to the service instances is already allowed.
+	SecurityGroupsNone SecurityGroupsMode = "none"
+)
+
 // Timeouts aggregates the timeouts configuration.
 type Timeouts struct {
 	CFPush          time.Duration `yaml:"cf_push"`
 	CFStart         time.Duration `yaml:"cf_start"`
 	CFCreateService time.Duration `yaml:"cf_create_service"`
 }
+
+// Merge returns the timeouts with the non-zero overrides applied.
+func (timeouts Timeouts) Merge(overrides Timeouts) Timeouts {
+	if overrides.CFPush != 0 {
+		timeouts.CFPush = overrides.CFPush
+	}
+	if overrides.CFStart != 0 {
+		timeouts.CFStart = overrides.CFStart
+	}
+	if overrides.CFCreateService != 0 {
+		timeouts.CFCreateService = overrides.CFCreateService
+	}
+	return timeouts
+}
+
+// stringKeys converts the nested maps decoded by yaml.v2, keyed by interface{}, into maps keyed by
+// string so they can be encoded as JSON.
+func stringKeys(params map[string]interface{}) map[string]interface{} {
+	for key, value := range params {
+		params[key] = stringKeysValue(value)
+	}
+	return params
+}
+
+func stringKeysValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(value))
+		for key, nested := range value {
+			converted[fmt.Sprint(key)] = stringKeysValue(nested)
+		}
+		return converted
+	case map[string]interface{}:
+		return stringKeys(value)
+	case []interface{}:
+		for i, nested := range value {
+			value[i] = stringKeysValue(nested)
+		}
+		return value
+	default:
+		return value
+	}
+}
+
+// Soak configures the soak mode, in which every app and service is kept alive and probed
+// periodically before being destroyed.
+type Soak struct {
+	Enabled         bool          `yaml:"enabled"`
+	Duration        time.Duration `yaml:"duration"`
+	Interval        time.Duration `yaml:"interval"`
+	MinAvailability float64       `yaml:"min_availability"`
+}
+
+// Upgrade configures the upgrade compatibility scenario. After seeding the service instances, the
+// scenario runs the Command, if set, or waits for the SignalFile to exist before asserting that the
+// instances survived the Minibroker upgrade.
+type Upgrade struct {
+	Enabled    bool          `yaml:"enabled"`
+	Command    string        `yaml:"command"`
+	SignalFile string        `yaml:"signal_file"`
+	Timeout    time.Duration `yaml:"timeout"`
+}
+
+// Probe configures the connectivity smoke test of the plans discovered in the catalog, using the
+// probeapp. Only the newest plan of each class not covered by an enabled test is probed, unless the
+// plans of the class are listed. It requires the admin user.
+type Probe struct {
+	Enabled bool `yaml:"enabled"`
+	// Classes restricts the probed classes. Every class registered by Minibroker is probed when
+	// empty.
+	Classes []string `yaml:"classes"`
+	// Plans lists the plans probed for each class, instead of its newest plan.
+	Plans map[string][]string `yaml:"plans"`
+}
+
+// MergeParams returns a deep copy of params with overrides merged over it. Nested maps are merged
+// recursively and any other override value replaces the original one.
+func MergeParams(params map[string]interface{}, overrides map[string]interface{}) map[string]interface{} {
+	if params == nil && overrides == nil {
+		return nil
+	}
+	merged := make(map[string]interface{}, len(params)+len(overrides))
+	for key, value := range params {
+		if nested, ok := value.(map[string]interface{}); ok {
+			value = MergeParams(nested, nil)
+		}
+		merged[key] = value
+	}
+	for key, value := range overrides {
+		nestedOverrides, overrideIsMap := value.(map[string]interface{})
+		nested, isMap := merged[key].(map[string]interface{})
+		if overrideIsMap && isMap {
+			merged[key] = MergeParams(nested, nestedOverrides)
+		} else if overrideIsMap {
+			merged[key] = MergeParams(nestedOverrides, nil)
+		} else {
+			merged[key] = value
+		}
+	}
+	return merged
+}
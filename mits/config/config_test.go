@@ -0,0 +1,326 @@
+/*
+   Copyright 2020 SUSE
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package config_test
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/SUSE/minibroker-integration-tests/mits/config"
+)
+
+const validConfig = `
+cf:
+  api:
+    endpoint: https://api.example.com
+  admin:
+    username: admin
+    password: secret
+minibroker:
+  api:
+    endpoint: http://minibroker.minibroker.svc
+tests:
+  redis:
+    enabled: true
+    class: redis
+    plan: 5-0-7
+timeouts:
+  cf_push: 3m
+  cf_start: 10m
+  cf_create_service: 10m
+`
+
+func lookupEnv(env map[string]string) config.LookupEnv {
+	return func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+}
+
+var _ = Describe("Load", func() {
+	var configPath string
+
+	writeConfig := func(content string) {
+		configFile, err := ioutil.TempFile("", "mits-config-*.yaml")
+		Expect(err).NotTo(HaveOccurred())
+		defer configFile.Close()
+		_, err = configFile.WriteString(content)
+		Expect(err).NotTo(HaveOccurred())
+		configPath = configFile.Name()
+	}
+
+	AfterEach(func() {
+		os.Remove(configPath)
+	})
+
+	It("should load a valid config", func() {
+		writeConfig(validConfig)
+		c, err := config.Load(configPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.CF.Admin.Password).To(Equal("secret"))
+		Expect(c.Tests.Redis.Plan).To(Equal("5-0-7"))
+		Expect(c.SecurityGroups.Mode).To(Equal(config.SecurityGroupsPerInstance))
+	})
+
+	It("should override the config from the environment", func() {
+		writeConfig(validConfig)
+		os.Setenv("MITS_CF_ADMIN_PASSWORD", "from-env")
+		defer os.Unsetenv("MITS_CF_ADMIN_PASSWORD")
+		c, err := config.Load(configPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.CF.Admin.Password).To(Equal("from-env"))
+	})
+
+	It("should load the per-test params and timeouts", func() {
+		writeConfig(strings.Replace(validConfig, "    plan: 5-0-7\n", `    plan: 5-0-7
+    params:
+      persistence:
+        storageClass: fast
+        sizes: [{size: 1Gi}]
+    bind_params:
+      readonly: true
+    tls_params:
+      tls:
+        enabled: true
+    timeouts:
+      cf_start: 20m
+`, 1))
+		c, err := config.Load(configPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.Tests.Redis.Params).To(Equal(map[string]interface{}{
+			"persistence": map[string]interface{}{
+				"storageClass": "fast",
+				"sizes":        []interface{}{map[string]interface{}{"size": "1Gi"}},
+			},
+		}))
+		Expect(c.Tests.Redis.BindParams).To(Equal(map[string]interface{}{"readonly": true}))
+		Expect(c.Tests.Redis.TLSParams).To(Equal(map[string]interface{}{
+			"tls": map[string]interface{}{"enabled": true},
+		}))
+		Expect(c.Timeouts.Merge(c.Tests.Redis.Timeouts)).To(Equal(config.Timeouts{
+			CFPush:          3 * time.Minute,
+			CFStart:         20 * time.Minute,
+			CFCreateService: 10 * time.Minute,
+		}))
+	})
+
+	It("should load the extra tests", func() {
+		writeConfig(validConfig + `extra_tests:
+- name: memcached
+  asset: memcachedapp
+  enabled: true
+  class: memcached
+  plan: 1-5-20
+  params:
+    resources: {requests: {memory: 64Mi}}
+`)
+		c, err := config.Load(configPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.ExtraTests).To(HaveLen(1))
+		Expect(c.ExtraTests[0].Name).To(Equal("memcached"))
+		Expect(c.ExtraTests[0].Asset).To(Equal("memcachedapp"))
+		Expect(c.ExtraTests[0].Class).To(Equal("memcached"))
+		Expect(c.ExtraTests[0].Params).To(Equal(map[string]interface{}{
+			"resources": map[string]interface{}{
+				"requests": map[string]interface{}{"memory": "64Mi"},
+			},
+		}))
+		Expect(c.TestConfigs()).To(ContainElement(c.ExtraTests[0].TestConfig))
+	})
+
+	It("should load the enabled scenarios", func() {
+		writeConfig(validConfig + `scenarios:
+  service_keys: true
+  tls: true
+`)
+		os.Setenv("MITS_SCENARIOS_MULTIPLE_APPS", "true")
+		defer os.Unsetenv("MITS_SCENARIOS_MULTIPLE_APPS")
+		c, err := config.Load(configPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.Scenarios).To(Equal(config.Scenarios{MultipleApps: true, ServiceKeys: true, TLS: true}))
+	})
+
+	It("should fail for an invalid config", func() {
+		writeConfig(`
+cf:
+  api:
+    endpoint: ~
+`)
+		_, err := config.Load(configPath)
+		Expect(err).To(MatchError(ContainSubstring("cf.api.endpoint must be set")))
+	})
+})
+
+var _ = Describe("ApplyEnv", func() {
+	It("should override fields of any type", func() {
+		var c config.Config
+		err := config.ApplyEnv(&c, lookupEnv(map[string]string{
+			"MITS_CF_ADMIN_PASSWORD":          "p@ss: #word",
+			"MITS_TESTS_MONGODB_ENABLED":      "true",
+			"MITS_TIMEOUTS_CF_PUSH":           "5m",
+			"MITS_SOAK_MIN_AVAILABILITY":      "0.5",
+			"MITS_SECURITY_GROUPS_MODE":       "none",
+			"MITS_CF_SPACE_DEVELOPER_ENABLED": "false",
+		}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.CF.Admin.Password).To(Equal("p@ss: #word"))
+		Expect(c.Tests.MongoDB.Enabled).To(BeTrue())
+		Expect(c.Timeouts.CFPush).To(Equal(5 * time.Minute))
+		Expect(c.Soak.MinAvailability).To(Equal(0.5))
+		Expect(c.SecurityGroups.Mode).To(Equal(config.SecurityGroupsNone))
+	})
+
+	It("should fail for values of the wrong type", func() {
+		var c config.Config
+		err := config.ApplyEnv(&c, lookupEnv(map[string]string{
+			"MITS_TESTS_REDIS_ENABLED": "maybe",
+		}))
+		Expect(err).To(MatchError(ContainSubstring("MITS_TESTS_REDIS_ENABLED")))
+	})
+})
+
+var _ = Describe("Validate", func() {
+	It("should aggregate all the problems", func() {
+		var c config.Config
+		c.Tests.Redis.Enabled = true
+		c.SecurityGroups.Mode = "bogus"
+		err := c.Validate()
+		Expect(err).To(BeAssignableToTypeOf(&config.ValidationError{}))
+		Expect(err.(*config.ValidationError).Problems).To(ConsistOf(
+			"cf.api.endpoint must be set",
+			"cf.admin.username must be set",
+			"cf.admin.password must be set",
+			"minibroker.api.endpoint must be set",
+			"tests.redis.class must be set",
+			"tests.redis.plan must be set",
+			`security_groups.mode "bogus" must be one of "per_instance", "shared" or "none"`,
+			"timeouts.cf_push must be a positive duration",
+			"timeouts.cf_start must be a positive duration",
+			"timeouts.cf_create_service must be a positive duration",
+		))
+	})
+
+	It("should reject incomplete or duplicate extra tests", func() {
+		var c config.Config
+		c.ExtraTests = []config.ExtraTest{
+			{Name: "memcached", Asset: "memcachedapp", TestConfig: config.TestConfig{Enabled: true, Class: "memcached", Plan: "1-5-20"}},
+			{Name: "memcached", TestConfig: config.TestConfig{Enabled: true, Class: "memcached"}},
+			{TestConfig: config.TestConfig{Enabled: false}},
+		}
+		err := c.Validate()
+		Expect(err).To(BeAssignableToTypeOf(&config.ValidationError{}))
+		Expect(err.(*config.ValidationError).Problems).To(ContainElements(
+			"extra_tests[1].asset must be set",
+			"extra_tests[1].plan must be set",
+			`extra_tests[1].name "memcached" is not unique`,
+		))
+		Expect(err.(*config.ValidationError).Problems).NotTo(ContainElement(ContainSubstring("extra_tests[0]")))
+		Expect(err.(*config.ValidationError).Problems).NotTo(ContainElement(ContainSubstring("extra_tests[2]")))
+	})
+
+	It("should reject a soak availability outside of (0, 1]", func() {
+		var c config.Config
+		c.Soak = config.Soak{Enabled: true, Duration: time.Hour, Interval: time.Minute}
+		for _, minAvailability := range []float64{-0.5, 0, 1.01} {
+			c.Soak.MinAvailability = minAvailability
+			err := c.Validate()
+			Expect(err).To(BeAssignableToTypeOf(&config.ValidationError{}))
+			Expect(err.(*config.ValidationError).Problems).To(ContainElement(
+				"soak.min_availability must be greater than 0 and at most 1",
+			), "min_availability %v", minAvailability)
+		}
+		for _, minAvailability := range []float64{0.5, 1} {
+			c.Soak.MinAvailability = minAvailability
+			err := c.Validate()
+			Expect(err.(*config.ValidationError).Problems).NotTo(ContainElement(
+				ContainSubstring("soak."),
+			), "min_availability %v", minAvailability)
+		}
+	})
+
+	It("should reject per-instance security groups in the space developer mode", func() {
+		var c config.Config
+		c.CF.API.Endpoint = "https://api.example.com"
+		c.CF.SpaceDeveloper = config.SpaceDeveloper{
+			Enabled:      true,
+			Username:     "developer",
+			Password:     "secret",
+			Organization: "org",
+			Space:        "space",
+		}
+		c.Minibroker.API.Endpoint = "http://minibroker.minibroker.svc"
+		c.SecurityGroups.Mode = config.SecurityGroupsPerInstance
+		c.Timeouts = config.Timeouts{CFPush: time.Minute, CFStart: time.Minute, CFCreateService: time.Minute}
+		err := c.Validate()
+		Expect(err).To(MatchError(ContainSubstring("requires the admin user")))
+	})
+
+	It("should reject the shared security group without the admin user", func() {
+		var c config.Config
+		c.CF.API.Endpoint = "https://api.example.com"
+		c.CF.SpaceDeveloper = config.SpaceDeveloper{
+			Enabled:      true,
+			Username:     "developer",
+			Password:     "secret",
+			Organization: "org",
+			Space:        "space",
+		}
+		c.Minibroker.API.Endpoint = "http://minibroker.minibroker.svc"
+		c.SecurityGroups = config.SecurityGroups{Mode: config.SecurityGroupsShared, SharedName: "mits"}
+		c.Timeouts = config.Timeouts{CFPush: time.Minute, CFStart: time.Minute, CFCreateService: time.Minute}
+		err := c.Validate()
+		Expect(err).To(MatchError(ContainSubstring(`security_groups.mode "shared" requires the admin user`)))
+
+		c.CF.Admin.Username = "admin"
+		c.CF.Admin.Password = "secret"
+		Expect(c.Validate()).To(Succeed())
+	})
+
+	It("should reject the scenarios and the probe unsupported by the config", func() {
+		var c config.Config
+		c.CF.API.Endpoint = "https://api.example.com"
+		c.CF.SpaceDeveloper = config.SpaceDeveloper{
+			Enabled:      true,
+			Username:     "developer",
+			Password:     "secret",
+			Organization: "org",
+			Space:        "space",
+		}
+		c.Minibroker.API.Endpoint = "http://minibroker.minibroker.svc"
+		c.Minibroker.Provisioning.OverrideParams.Enabled = true
+		c.SecurityGroups.Mode = config.SecurityGroupsNone
+		c.Timeouts = config.Timeouts{CFPush: time.Minute, CFStart: time.Minute, CFCreateService: time.Minute}
+		c.Scenarios = config.Scenarios{MultipleApps: true, Sharing: true, TLS: true}
+		c.Probe.Enabled = true
+		err := c.Validate()
+		Expect(err).To(BeAssignableToTypeOf(&config.ValidationError{}))
+		Expect(err.(*config.ValidationError).Problems).To(ConsistOf(
+			"scenarios.sharing requires the admin user to create a second space",
+			"scenarios.tls can't be used with minibroker.provisioning.override_params, which would ignore the tls_params",
+			"probe requires the admin user to enable the service access to the probed plans",
+		))
+
+		c.Scenarios = config.Scenarios{MultipleApps: true}
+		c.Probe.Enabled = false
+		Expect(c.Validate()).To(Succeed())
+	})
+})
@@ -0,0 +1,69 @@
+/*
+   Copyright 2020 SUSE
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// EnvPrefix prefixes the names of all the environment variables that override the configuration.
+const EnvPrefix = "MITS"
+
+// LookupEnv retrieves the value of an environment variable. os.LookupEnv satisfies it.
+type LookupEnv func(key string) (string, bool)
+
+// ApplyEnv overrides the fields of config from the environment. The name of the variable for a
+// field is made of EnvPrefix and the YAML keys leading to the field, upper-cased and joined with
+// underscores, e.g. MITS_CF_ADMIN_PASSWORD for cf.admin.password. String values are used as-is and
+// any other value is parsed as YAML, e.g. MITS_TIMEOUTS_CF_PUSH=5m.
+func ApplyEnv(config *Config, lookupEnv LookupEnv) error {
+	return applyEnv(reflect.ValueOf(config).Elem(), EnvPrefix, lookupEnv)
+}
+
+func applyEnv(value reflect.Value, name string, lookupEnv LookupEnv) error {
+	if value.Kind() == reflect.Struct {
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			key := strings.Split(field.Tag.Get("yaml"), ",")[0]
+			if key == "" || key == "-" {
+				continue
+			}
+			fieldName := name + "_" + strings.ToUpper(key)
+			if err := applyEnv(value.Field(i), fieldName, lookupEnv); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	env, ok := lookupEnv(name)
+	if !ok {
+		return nil
+	}
+	if value.Kind() == reflect.String {
+		value.SetString(env)
+		return nil
+	}
+	if err := yaml.UnmarshalStrict([]byte(env), value.Addr().Interface()); err != nil {
+		return fmt.Errorf("failed to apply %s from the environment: %w", name, err)
+	}
+	return nil
+}
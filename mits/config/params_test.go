@@ -0,0 +1,81 @@
+/*
+   Copyright 2020 SUSE
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package config_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/SUSE/minibroker-integration-tests/mits/config"
+)
+
+var _ = Describe("MergeParams", func() {
+	DescribeTable("merging the overrides over the params",
+		func(params map[string]interface{}, overrides map[string]interface{}, expected map[string]interface{}) {
+			Expect(config.MergeParams(params, overrides)).To(Equal(expected))
+		},
+		Entry("without params nor overrides", nil, nil, nil),
+		Entry("without overrides",
+			map[string]interface{}{"a": 1},
+			nil,
+			map[string]interface{}{"a": 1},
+		),
+		Entry("without params",
+			nil,
+			map[string]interface{}{"a": map[string]interface{}{"b": 1}},
+			map[string]interface{}{"a": map[string]interface{}{"b": 1}},
+		),
+		Entry("with overrides taking precedence",
+			map[string]interface{}{"a": 1, "b": 2},
+			map[string]interface{}{"b": 3},
+			map[string]interface{}{"a": 1, "b": 3},
+		),
+		Entry("with nested maps merged recursively",
+			map[string]interface{}{
+				"persistence": map[string]interface{}{"enabled": false, "size": "1Gi"},
+				"cluster":     map[string]interface{}{"enabled": false},
+			},
+			map[string]interface{}{
+				"persistence": map[string]interface{}{"size": "8Gi"},
+			},
+			map[string]interface{}{
+				"persistence": map[string]interface{}{"enabled": false, "size": "8Gi"},
+				"cluster":     map[string]interface{}{"enabled": false},
+			},
+		),
+		Entry("with a scalar override replacing a nested map",
+			map[string]interface{}{"tls": map[string]interface{}{"enabled": true}},
+			map[string]interface{}{"tls": false},
+			map[string]interface{}{"tls": false},
+		),
+		Entry("with a nested map override replacing a scalar",
+			map[string]interface{}{"tls": false},
+			map[string]interface{}{"tls": map[string]interface{}{"enabled": true}},
+			map[string]interface{}{"tls": map[string]interface{}{"enabled": true}},
+		),
+	)
+
+	It("should not modify its inputs", func() {
+		params := map[string]interface{}{"a": map[string]interface{}{"b": 1}}
+		overrides := map[string]interface{}{"a": map[string]interface{}{"c": 2}}
+		merged := config.MergeParams(params, overrides)
+		merged["a"].(map[string]interface{})["b"] = 3
+		Expect(params).To(Equal(map[string]interface{}{"a": map[string]interface{}{"b": 1}}))
+		Expect(overrides).To(Equal(map[string]interface{}{"a": map[string]interface{}{"c": 2}}))
+	})
+})
@@ -0,0 +1,150 @@
+/*
+   Copyright 2020 SUSE
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// ValidationError aggregates all the problems found while validating a configuration.
+type ValidationError struct {
+	Problems []string
+}
+
+func (err *ValidationError) Error() string {
+	return fmt.Sprintf("invalid config:\n  - %s", strings.Join(err.Problems, "\n  - "))
+}
+
+// Validate checks that the configuration is complete and consistent. All the problems found are
+// reported at once in a *ValidationError.
+func (config *Config) Validate() error {
+	var problems []string
+	problemf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	required := func(value string, key string) {
+		if value == "" {
+			problemf("%s must be set", key)
+		}
+	}
+	positive := func(value time.Duration, key string) {
+		if value <= 0 {
+			problemf("%s must be a positive duration", key)
+		}
+	}
+
+	required(config.CF.API.Endpoint, "cf.api.endpoint")
+	spaceDeveloper := config.CF.SpaceDeveloper
+	if spaceDeveloper.Enabled {
+		required(spaceDeveloper.Username, "cf.space_developer.username")
+		required(spaceDeveloper.Password, "cf.space_developer.password")
+		required(spaceDeveloper.Organization, "cf.space_developer.organization")
+		required(spaceDeveloper.Space, "cf.space_developer.space")
+		if config.CF.Admin.Username != "" {
+			required(config.CF.Admin.Password, "cf.admin.password")
+		}
+	} else {
+		required(config.CF.Admin.Username, "cf.admin.username")
+		required(config.CF.Admin.Password, "cf.admin.password")
+	}
+
+	required(config.Minibroker.API.Endpoint, "minibroker.api.endpoint")
+
+	checkTest := func(testConfig TestConfig, key string) {
+		required(testConfig.Class, key+".class")
+		required(testConfig.Plan, key+".plan")
+		if testConfig.Timeouts.CFPush < 0 || testConfig.Timeouts.CFStart < 0 || testConfig.Timeouts.CFCreateService < 0 {
+			problemf("%s.timeouts must not be negative", key)
+		}
+	}
+	tests := reflect.ValueOf(config.Tests)
+	for i := 0; i < tests.NumField(); i++ {
+		if test := tests.Field(i).Interface().(TestConfig); test.Enabled {
+			checkTest(test, "tests."+tests.Type().Field(i).Tag.Get("yaml"))
+		}
+	}
+	names := make(map[string]bool, len(config.ExtraTests))
+	for i, extraTest := range config.ExtraTests {
+		if !extraTest.Enabled {
+			continue
+		}
+		key := fmt.Sprintf("extra_tests[%d]", i)
+		required(extraTest.Name, key+".name")
+		required(extraTest.Asset, key+".asset")
+		checkTest(extraTest.TestConfig, key)
+		if names[extraTest.Name] {
+			problemf("%s.name %q is not unique", key, extraTest.Name)
+		}
+		names[extraTest.Name] = true
+	}
+
+	if config.Scenarios.Sharing && config.CF.Admin.Username == "" {
+		problemf("scenarios.sharing requires the admin user to create a second space")
+	}
+	if config.Scenarios.TLS && config.Minibroker.Provisioning.OverrideParams.Enabled {
+		problemf("scenarios.tls can't be used with minibroker.provisioning.override_params, which would ignore the tls_params")
+	}
+
+	switch config.SecurityGroups.Mode {
+	case SecurityGroupsPerInstance:
+		if spaceDeveloper.Enabled {
+			problemf("security_groups.mode %q requires the admin user and can't be used with cf.space_developer", config.SecurityGroups.Mode)
+		}
+	case SecurityGroupsShared:
+		required(config.SecurityGroups.SharedName, "security_groups.shared_name")
+		if config.CF.Admin.Username == "" {
+			problemf("security_groups.mode %q requires the admin user to bind the shared security group, use %q if the space already allows the egress traffic",
+				config.SecurityGroups.Mode, SecurityGroupsNone)
+		}
+	case SecurityGroupsNone:
+	default:
+		problemf("security_groups.mode %q must be one of %q, %q or %q",
+			config.SecurityGroups.Mode, SecurityGroupsPerInstance, SecurityGroupsShared, SecurityGroupsNone)
+	}
+
+	positive(config.Timeouts.CFPush, "timeouts.cf_push")
+	positive(config.Timeouts.CFStart, "timeouts.cf_start")
+	positive(config.Timeouts.CFCreateService, "timeouts.cf_create_service")
+
+	if config.Soak.Enabled {
+		positive(config.Soak.Duration, "soak.duration")
+		positive(config.Soak.Interval, "soak.interval")
+		// An availability of 0 would let a soak pass without a single successful probe.
+		if config.Soak.MinAvailability <= 0 || config.Soak.MinAvailability > 1 {
+			problemf("soak.min_availability must be greater than 0 and at most 1")
+		}
+	}
+
+	if config.Upgrade.Enabled {
+		if config.Upgrade.Command == "" && config.Upgrade.SignalFile == "" {
+			problemf("either upgrade.command or upgrade.signal_file must be set")
+		}
+		positive(config.Upgrade.Timeout, "upgrade.timeout")
+	}
+
+	if config.Probe.Enabled && config.CF.Admin.Username == "" {
+		problemf("probe requires the admin user to enable the service access to the probed plans")
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
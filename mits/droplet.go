@@ -0,0 +1,197 @@
+/*
+   Copyright 2020 SUSE
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package mits
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gexec"
+
+	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/generator"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/workflowhelpers"
+
+	"github.com/SUSE/minibroker-integration-tests/mits/config"
+)
+
+// DropletCache holds a droplet staged once for the whole suite for each asset app. The apps pushed
+// by the scenarios are created from a copy of it instead of being staged again.
+type DropletCache struct {
+	// Droplets maps the asset app names to the guids of their droplets.
+	Droplets map[string]string `json:"droplets"`
+	// CopyAsAdmin is set when the droplets are staged in a space the parallel nodes can't read
+	// from, so they are copied as the admin user.
+	CopyAsAdmin bool `json:"copy_as_admin"`
+}
+
+// dropletCache is the cache used by pushAsset, if any.
+var dropletCache *DropletCache
+
+// UseDropletCache makes the scenarios create their apps from the droplets in the cache. The asset
+// apps missing from the cache are still pushed and staged.
+func UseDropletCache(cache *DropletCache) {
+	dropletCache = cache
+}
+
+// StageAssets pushes and stages each asset app once, without any instance, and returns the cache of
+// their droplets. The source apps must outlive every app created from the cache, so the returned
+// function deleting them is only called once the whole suite is done.
+func StageAssets(
+	testSetup *workflowhelpers.ReproducibleTestSuiteSetup,
+	mitsConfig *config.Config,
+	assets []string,
+) (*DropletCache, func()) {
+	cache := &DropletCache{
+		Droplets:    make(map[string]string),
+		CopyAsAdmin: !mitsConfig.CF.SpaceDeveloper.Enabled,
+	}
+	var deleteApps []func()
+	deleteAll := func() {
+		for _, deleteApp := range deleteApps {
+			deleteApp()
+		}
+	}
+	defer cleanupOnFailure(deleteAll)
+
+	for _, asset := range assets {
+		appName := generator.PrefixedRandomName("mits", asset)
+		deleteApps = append(deleteApps, pushAsset(testSetup, mitsConfig.Timeouts, appName, "", asset))
+
+		By("staging the " + asset + " droplet for the suite")
+		Expect(
+			cf.Cf("scale", appName, "-i", "0").
+				Wait(testSetup.ShortTimeout()),
+		).To(Exit(0))
+		Expect(
+			cf.Cf("start", appName).
+				Wait(mitsConfig.Timeouts.CFStart),
+		).To(Exit(0))
+
+		appGUID, err := cfGUID(GinkgoWriter, testSetup.ShortTimeout(), "app", "--guid", appName)
+		Expect(err).NotTo(HaveOccurred())
+		var droplet struct {
+			GUID string `json:"guid"`
+		}
+		err = cfCurl(GinkgoWriter, testSetup.ShortTimeout(), &droplet, "/v3/apps/"+appGUID+"/droplets/current")
+		Expect(err).NotTo(HaveOccurred())
+		cache.Droplets[asset] = droplet.GUID
+	}
+	return cache, deleteAll
+}
+
+// createFromDroplet creates the app from a copy of the droplet, starting the asset with the given
+// command, instead of pushing and staging it. The app gets the same route cf push would map.
+func createFromDroplet(
+	testSetup *workflowhelpers.ReproducibleTestSuiteSetup,
+	appName string,
+	dropletGUID string,
+	command string,
+) {
+	Expect(
+		cf.Cf("create-app", appName).
+			Wait(testSetup.ShortTimeout()),
+	).To(Exit(0))
+	appGUID, err := cfGUID(GinkgoWriter, testSetup.ShortTimeout(), "app", "--guid", appName)
+	Expect(err).NotTo(HaveOccurred())
+
+	copyDroplet := func() {
+		err := setDropletCopy(appGUID, dropletGUID, command, GinkgoWriter, testSetup.ShortTimeout())
+		Expect(err).NotTo(HaveOccurred())
+	}
+	if dropletCache.CopyAsAdmin {
+		workflowhelpers.AsUser(testSetup.AdminUserContext(), testSetup.ShortTimeout(), copyDroplet)
+	} else {
+		copyDroplet()
+	}
+
+	By("mapping a route to the test app on the default domain")
+	domain, err := defaultDomain(testSetup.TestSpace.OrganizationName(), GinkgoWriter, testSetup.ShortTimeout())
+	Expect(err).NotTo(HaveOccurred())
+	Expect(
+		cf.Cf("create-route", domain, "--hostname", appName).
+			Wait(testSetup.ShortTimeout()),
+	).To(Exit(0))
+	Expect(
+		cf.Cf("map-route", appName, domain, "--hostname", appName).
+			Wait(testSetup.ShortTimeout()),
+	).To(Exit(0))
+}
+
+// defaultDomain returns the name of the default domain of the organization, which cf push uses for
+// the routes it maps.
+func defaultDomain(orgName string, stderr io.Writer, timeout time.Duration) (string, error) {
+	orgGUID, err := cfGUID(stderr, timeout, "org", "--guid", orgName)
+	if err != nil {
+		return "", fmt.Errorf("failed to get the default domain: %w", err)
+	}
+	var domain struct {
+		Name string `json:"name"`
+	}
+	if err := cfCurl(stderr, timeout, &domain, "/v3/organizations/"+orgGUID+"/domains/default"); err != nil {
+		return "", fmt.Errorf("failed to get the default domain: %w", err)
+	}
+	return domain.Name, nil
+}
+
+// setDropletCopy copies the droplet into the app, sets the copy as the current droplet of the app
+// and sets the command of its web process, since the one detected by the buildpack doesn't start the
+// asset.
+func setDropletCopy(appGUID string, dropletGUID string, command string, stderr io.Writer, timeout time.Duration) error {
+	var droplet struct {
+		GUID  string `json:"guid"`
+		State string `json:"state"`
+	}
+	body := fmt.Sprintf(`{"relationships":{"app":{"data":{"guid":%q}}}}`, appGUID)
+	if err := cfCurl(stderr, timeout, &droplet, "-X", "POST", "-d", body, "/v3/droplets?source_guid="+dropletGUID); err != nil {
+		return fmt.Errorf("failed to copy droplet: %w", err)
+	}
+
+	timeLimit := time.Now().Add(timeout)
+	for droplet.State != "STAGED" {
+		if droplet.State == "FAILED" || droplet.State == "EXPIRED" {
+			return fmt.Errorf("failed to copy droplet: the droplet copy is %s", droplet.State)
+		}
+		if time.Now().After(timeLimit) {
+			return fmt.Errorf("failed to copy droplet: timed out")
+		}
+		time.Sleep(time.Second)
+		if err := cfCurl(stderr, timeout, &droplet, "/v3/droplets/"+droplet.GUID); err != nil {
+			return fmt.Errorf("failed to copy droplet: %w", err)
+		}
+	}
+
+	body = fmt.Sprintf(`{"data":{"guid":%q}}`, droplet.GUID)
+	if err := cfCurl(stderr, timeout, &struct{}{}, "-X", "PATCH", "-d", body, "/v3/apps/"+appGUID+"/relationships/current_droplet"); err != nil {
+		return fmt.Errorf("failed to set the current droplet: %w", err)
+	}
+
+	var process struct {
+		GUID string `json:"guid"`
+	}
+	if err := cfCurl(stderr, timeout, &process, "/v3/apps/"+appGUID+"/processes/web"); err != nil {
+		return fmt.Errorf("failed to set the start command: %w", err)
+	}
+	body = fmt.Sprintf(`{"command":%q}`, command)
+	if err := cfCurl(stderr, timeout, &struct{}{}, "-X", "PATCH", "-d", body, "/v3/processes/"+process.GUID); err != nil {
+		return fmt.Errorf("failed to set the start command: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+/*
+   Copyright 2020 SUSE
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package mits
+
+import (
+	. "github.com/onsi/ginkgo"
+
+	"github.com/cloudfoundry-incubator/cf-test-helpers/workflowhelpers"
+
+	"github.com/SUSE/minibroker-integration-tests/mits/config"
+)
+
+// Suite returns the state shared by the specs, which is only known once the suite is set up.
+type Suite func() (testSetup *workflowhelpers.ReproducibleTestSuiteSetup, mitsConfig *config.Config, serviceBrokerName string)
+
+// DescribeExtraTests builds the basic specs for the extra tests from their config entries alone.
+// The asset apps must follow the asset app contract, so the suite also runs the enabled scenarios
+// for them, the same way as for the built-in tests. It must be called before the specs run, once
+// the config is loaded.
+func DescribeExtraTests(extraTests []config.ExtraTest, suite Suite) bool {
+	return Describe("Extra service classes", func() {
+		for _, extraTest := range extraTests {
+			extraTest := extraTest
+
+			Describe(extraTest.Name, func() {
+				BeforeEach(func() {
+					if !extraTest.Enabled {
+						Skip("All " + extraTest.Name + " tests are disabled")
+					}
+					if _, mitsConfig, _ := suite(); mitsConfig.Upgrade.Enabled {
+						Skip("Only the upgrade scenario runs when enabled")
+					}
+				})
+
+				It("should deploy and connect", func() {
+					testSetup, mitsConfig, serviceBrokerName := suite()
+					SimpleAppAndService(testSetup, mitsConfig, extraTest.TestConfig, serviceBrokerName, extraTest.Asset, nil)
+				})
+			})
+		}
+	})
+}
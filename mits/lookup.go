@@ -0,0 +1,113 @@
+/*
+   Copyright 2020 SUSE
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package mits
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gexec"
+
+	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/generator"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/workflowhelpers"
+
+	"github.com/SUSE/minibroker-integration-tests/mits/config"
+)
+
+// lookupEnvs are the environment variables selecting how the asset apps look up their service.
+var lookupEnvs = []string{"SERVICE_NAME", "SERVICE_LABEL", "SERVICE_TAG"}
+
+// ServiceLookup asserts that the label and tags of the binding in VCAP_SERVICES match the catalog,
+// and that the asset app finds the bound service by name, label, tag and as the only bound service.
+// The lookup by tag is skipped if the service offering has no tags.
+func ServiceLookup(
+	testSetup *workflowhelpers.ReproducibleTestSuiteSetup,
+	mitsConfig *config.Config,
+	serviceBrokerName string,
+	c Case,
+) {
+	timeouts := mitsConfig.Timeouts.Merge(c.TestConfig.Timeouts)
+	appName := generator.PrefixedRandomName(c.TestConfig.Class, "app")
+
+	By("fetching the plan from the catalog")
+	plan, err := FetchPlan(serviceBrokerName, c.TestConfig.Class, c.TestConfig.Plan, GinkgoWriter, testSetup.ShortTimeout())
+	Expect(err).NotTo(HaveOccurred())
+
+	params := config.MergeParams(c.Params, c.TestConfig.Params)
+	service, cleanup := provisionAndBind(testSetup, mitsConfig, serviceBrokerName, c.TestConfig, params, c.Asset, pushAsset, appName)
+	defer cleanup()
+
+	By("asserting the label and tags of the binding in VCAP_SERVICES")
+	appGUID, err := cfGUID(GinkgoWriter, testSetup.ShortTimeout(), "app", "--guid", appName)
+	Expect(err).NotTo(HaveOccurred())
+	var env struct {
+		SystemEnv struct {
+			VCAPServices map[string][]struct {
+				Name  string   `json:"name"`
+				Label string   `json:"label"`
+				Tags  []string `json:"tags"`
+			} `json:"VCAP_SERVICES"`
+		} `json:"system_env_json"`
+	}
+	err = cfCurl(GinkgoWriter, testSetup.ShortTimeout(), &env, "/v3/apps/"+appGUID+"/env")
+	Expect(err).NotTo(HaveOccurred())
+	bound := env.SystemEnv.VCAPServices[c.TestConfig.Class]
+	Expect(bound).To(HaveLen(1), "no binding labeled %s in VCAP_SERVICES", c.TestConfig.Class)
+	Expect(bound[0].Name).To(Equal(service.name))
+	Expect(bound[0].Label).To(Equal(c.TestConfig.Class))
+	for _, tag := range plan.Offering.Tags {
+		Expect(bound[0].Tags).To(ContainElement(tag))
+	}
+
+	type serviceLookup struct {
+		name string
+		env  map[string]string
+	}
+	lookups := []serviceLookup{
+		{name: "name", env: map[string]string{"SERVICE_NAME": service.name}},
+		{name: "label", env: map[string]string{"SERVICE_LABEL": c.TestConfig.Class}},
+		{name: "only bound service", env: map[string]string{}},
+	}
+	if len(plan.Offering.Tags) > 0 {
+		lookups = append(lookups, serviceLookup{name: "tag", env: map[string]string{"SERVICE_TAG": plan.Offering.Tags[0]}})
+	} else {
+		fmt.Fprintf(GinkgoWriter, "Skipping the lookup by tag: the %s service offering has no tags\n", c.TestConfig.Class)
+	}
+
+	for _, lookup := range lookups {
+		By("starting the app looking the service up by " + lookup.name)
+		for _, name := range lookupEnvs {
+			if value, ok := lookup.env[name]; ok {
+				Expect(
+					cf.Cf("set-env", appName, name, value).
+						Wait(testSetup.ShortTimeout()),
+				).To(Exit(0))
+			} else {
+				Expect(
+					cf.Cf("unset-env", appName, name).
+						Wait(testSetup.ShortTimeout()),
+				).To(Exit(0))
+			}
+		}
+		Expect(
+			cf.Cf("restart", appName).
+				Wait(timeouts.CFStart),
+		).To(Exit(0))
+	}
+}
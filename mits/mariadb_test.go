@@ -29,6 +29,9 @@ var _ = Describe("MariaDB", func() {
 		if !mitsConfig.Tests.MariaDB.Enabled {
 			Skip("All MariaDB tests are disabled")
 		}
+		if mitsConfig.Upgrade.Enabled {
+			Skip("Only the upgrade scenario runs when enabled")
+		}
 	})
 
 	Context("Without overrideParams set", func() {
@@ -41,19 +44,11 @@ var _ = Describe("MariaDB", func() {
 		It("should deploy and connect WITH extra provisioning parameters", func() {
 			mits.SimpleAppAndService(
 				testSetup,
+				mitsConfig,
 				mitsConfig.Tests.MariaDB,
-				mitsConfig.Timeouts,
 				serviceBrokerName,
-				"assets/mysqlapp",
-				map[string]interface{}{
-					"db": map[string]interface{}{
-						"name": generator.PrefixedRandomName(mitsConfig.Tests.MariaDB.Class, "db"),
-						"user": generator.PrefixedRandomName(mitsConfig.Tests.MariaDB.Class, "user"),
-					},
-					"replication": map[string]interface{}{
-						"enabled": false,
-					},
-				},
+				"mysqlapp",
+				mariadbParams(),
 			)
 		})
 	})
@@ -68,12 +63,25 @@ var _ = Describe("MariaDB", func() {
 		It("should deploy and connect WITHOUT extra provisioning parameters", func() {
 			mits.SimpleAppAndService(
 				testSetup,
+				mitsConfig,
 				mitsConfig.Tests.MariaDB,
-				mitsConfig.Timeouts,
 				serviceBrokerName,
-				"assets/mysqlapp",
+				"mysqlapp",
 				nil,
 			)
 		})
 	})
 })
+
+// mariadbParams returns the extra provisioning parameters used for MariaDB.
+func mariadbParams() map[string]interface{} {
+	return map[string]interface{}{
+		"db": map[string]interface{}{
+			"name": generator.PrefixedRandomName(mitsConfig.Tests.MariaDB.Class, "db"),
+			"user": generator.PrefixedRandomName(mitsConfig.Tests.MariaDB.Class, "user"),
+		},
+		"replication": map[string]interface{}{
+			"enabled": false,
+		},
+	}
+}
@@ -17,9 +17,10 @@
 package mits_test
 
 import (
+	"encoding/json"
 	"os"
-	"reflect"
 	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -30,7 +31,9 @@ import (
 	"github.com/cloudfoundry-incubator/cf-test-helpers/generator"
 	"github.com/cloudfoundry-incubator/cf-test-helpers/workflowhelpers"
 
+	"github.com/SUSE/minibroker-integration-tests/mits"
 	"github.com/SUSE/minibroker-integration-tests/mits/config"
+	"github.com/SUSE/minibroker-integration-tests/mits/probe"
 )
 
 var (
@@ -38,20 +41,113 @@ var (
 
 	testSetup         *workflowhelpers.ReproducibleTestSuiteSetup
 	serviceBrokerName string
+
+	// The CF home directories swapped by the space developer mode setup.
+	originalCfHomeDir string
+	currentCfHomeDir  string
+
+	// deleteStagedAssets deletes the apps holding the droplets staged for the suite, on the first
+	// parallel node only.
+	deleteStagedAssets func()
+
+	// unbindSharedSecurityGroup reverts the binding of the shared security group to the test space,
+	// if any was made by the parallel node.
+	unbindSharedSecurityGroup func()
 )
 
 func TestMits(t *testing.T) {
 	RegisterFailHandler(Fail)
-	RunSpecs(t, "Mits Suite")
-}
 
-var _ = BeforeSuite(func() {
+	// The config is loaded before the specs are built since the extra tests are described by it, and
+	// the scenarios also run for them.
 	configPath, ok := os.LookupEnv("CONFIG_PATH")
-	Expect(ok).To(BeTrue())
+	if !ok {
+		t.Fatal("CONFIG_PATH is not set")
+	}
 	c, err := config.Load(configPath)
-	Expect(err).NotTo(HaveOccurred())
+	if err != nil {
+		t.Fatal(err)
+	}
 	mitsConfig = c
+	suite := func() (*workflowhelpers.ReproducibleTestSuiteSetup, *config.Config, string) {
+		return testSetup, mitsConfig, serviceBrokerName
+	}
+	mits.DescribeExtraTests(mitsConfig.ExtraTests, suite)
+	describeScenarios()
+	// The catalog probe has a spec for each probed plan, so the catalog is fetched from Minibroker
+	// before the specs are built, and before Minibroker is registered with CF.
+	if mitsConfig.Probe.Enabled {
+		catalog, err := probe.FetchCatalog(mitsConfig.Minibroker.API.Endpoint, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mits.DescribeCatalogProbe(probe.Select(catalog, mitsConfig), suite)
+	}
+
+	RunSpecs(t, "Mits Suite")
+}
+
+// The first parallel node stages each asset app once and shares the droplets with the other nodes,
+// which set up their own test space and service broker.
+var _ = SynchronizedBeforeSuite(func() []byte {
+	setupSuite()
+
+	var cache *mits.DropletCache
+	cache, deleteStagedAssets = mits.StageAssets(testSetup, mitsConfig, suiteAssets())
+	data, err := json.Marshal(cache)
+	Expect(err).NotTo(HaveOccurred())
+	return data
+}, func(data []byte) {
+	if testSetup == nil {
+		setupSuite()
+	}
+
+	var cache mits.DropletCache
+	Expect(json.Unmarshal(data, &cache)).To(Succeed())
+	mits.UseDropletCache(&cache)
+})
 
+// The first parallel node is torn down last, since the droplets copied by the other nodes are
+// staged in its test space.
+var _ = SynchronizedAfterSuite(func() {
+	if GinkgoParallelNode() != 1 {
+		teardownSuite()
+	}
+}, func() {
+	if deleteStagedAssets != nil {
+		deleteStagedAssets()
+	}
+	teardownSuite()
+})
+
+// suiteAssets returns the asset apps used by the enabled tests.
+func suiteAssets() []string {
+	used := make(map[string]bool)
+	var assets []string
+	use := func(asset string) {
+		if !used[asset] {
+			used[asset] = true
+			assets = append(assets, asset)
+		}
+	}
+	for _, serviceTest := range serviceTests {
+		if serviceTest.config().Enabled {
+			use(serviceTest.asset)
+		}
+	}
+	for _, extraTest := range mitsConfig.ExtraTests {
+		if extraTest.Enabled {
+			use(extraTest.Asset)
+		}
+	}
+	if mitsConfig.Probe.Enabled {
+		use("probeapp")
+	}
+	return assets
+}
+
+// setupSuite sets up the test space and registers the service broker for the parallel node.
+func setupSuite() {
 	serviceBrokerName = generator.PrefixedRandomName("mits", "minibroker")
 
 	cfg := helpersConfig.Config{
@@ -62,38 +158,126 @@ var _ = BeforeSuite(func() {
 		AdminPassword:     mitsConfig.CF.Admin.Password,
 		SkipSSLValidation: true,
 	}
+
+	spaceDeveloper := mitsConfig.CF.SpaceDeveloper
+	if !spaceDeveloper.Enabled {
+		testSetup = workflowhelpers.NewTestSuiteSetup(&cfg)
+		testSetup.Setup()
+
+		workflowhelpers.AsUser(testSetup.AdminUserContext(), testSetup.ShortTimeout(), func() {
+			registerServiceBroker(false)
+		})
+		if mitsConfig.SecurityGroups.Mode == config.SecurityGroupsShared {
+			unbindSharedSecurityGroup = mits.BindSharedSecurityGroup(testSetup, mitsConfig.SecurityGroups.SharedName)
+		}
+		return
+	}
+
+	// In the space developer mode, the tests run as an existing user in an existing space, which
+	// is never created nor deleted by MITS.
+	cfg.UseExistingUser = true
+	cfg.ShouldKeepUser = true
+	cfg.ExistingUser = spaceDeveloper.Username
+	cfg.ExistingUserPassword = spaceDeveloper.Password
+	cfg.UseExistingOrganization = true
+	cfg.ExistingOrganization = spaceDeveloper.Organization
+	cfg.UseExistingSpace = true
+	cfg.ExistingSpace = spaceDeveloper.Space
 	testSetup = workflowhelpers.NewTestSuiteSetup(&cfg)
-	testSetup.Setup()
-
-	workflowhelpers.AsUser(testSetup.AdminUserContext(), testSetup.ShortTimeout(), func() {
-		Expect(
-			cf.Cf("create-service-broker", serviceBrokerName, "user", "pass", mitsConfig.Minibroker.API.Endpoint).
-				Wait(testSetup.ShortTimeout()),
-		).To(Exit(0))
-
-		tests := reflect.ValueOf(mitsConfig.Tests)
-		for i := 0; i < tests.NumField(); i++ {
-			testConfig := tests.Field(i).Interface().(config.TestConfig)
-			if testConfig.Enabled {
-				Expect(
-					cf.Cf(
-						"enable-service-access", testConfig.Class,
-						"-p", testConfig.Plan,
-						"-b", serviceBrokerName,
-					).Wait(testSetup.ShortTimeout()),
-				).To(Exit(0))
-			}
+
+	// The admin user, when configured, only registers the service broker in a privileged
+	// pre-phase. Otherwise, the space developer registers a space-scoped service broker.
+	if hasAdminUser() {
+		workflowhelpers.AsUser(testSetup.AdminUserContext(), testSetup.ShortTimeout(), func() {
+			registerServiceBroker(false)
+		})
+	}
+	// The existing space is shared by the parallel nodes, so the shared security group is only bound
+	// by the first node, which is also torn down last. The config validation ensures the admin user
+	// is set in the shared mode.
+	if mitsConfig.SecurityGroups.Mode == config.SecurityGroupsShared && GinkgoParallelNode() == 1 {
+		unbindSharedSecurityGroup = mits.BindSharedSecurityGroup(testSetup, mitsConfig.SecurityGroups.SharedName)
+	}
+
+	regularUserContext := testSetup.RegularUserContext()
+	originalCfHomeDir, currentCfHomeDir = regularUserContext.SetCfHomeDir()
+	regularUserContext.Login()
+	regularUserContext.TargetSpace()
+
+	if !hasAdminUser() {
+		registerServiceBroker(true)
+	}
+}
+
+// teardownSuite deletes the service broker and the test space of the parallel node.
+func teardownSuite() {
+	if !mitsConfig.CF.SpaceDeveloper.Enabled {
+		if unbindSharedSecurityGroup != nil {
+			unbindSharedSecurityGroup()
 		}
-	})
-})
+		workflowhelpers.AsUser(testSetup.AdminUserContext(), testSetup.ShortTimeout(), func() {
+			deleteServiceBroker()
+		})
 
-var _ = AfterSuite(func() {
-	workflowhelpers.AsUser(testSetup.AdminUserContext(), testSetup.ShortTimeout(), func() {
-		Expect(
-			cf.Cf("delete-service-broker", serviceBrokerName, "-f").
-				Wait(testSetup.ShortTimeout()),
-		).To(Exit(0))
-	})
+		testSetup.Teardown()
+		return
+	}
 
-	testSetup.Teardown()
-})
+	if !hasAdminUser() {
+		deleteServiceBroker()
+	}
+
+	regularUserContext := testSetup.RegularUserContext()
+	regularUserContext.Logout()
+	regularUserContext.UnsetCfHomeDir(originalCfHomeDir, currentCfHomeDir)
+
+	if unbindSharedSecurityGroup != nil {
+		unbindSharedSecurityGroup()
+	}
+
+	if hasAdminUser() {
+		workflowhelpers.AsUser(testSetup.AdminUserContext(), testSetup.ShortTimeout(), func() {
+			deleteServiceBroker()
+		})
+	}
+}
+
+// hasAdminUser returns whether the admin user credentials are configured.
+func hasAdminUser() bool {
+	return mitsConfig.CF.Admin.Username != ""
+}
+
+// registerServiceBroker registers Minibroker with CF. A space-scoped service broker is registered
+// in the targeted space and its plans are visible there without enabling the service access.
+func registerServiceBroker(spaceScoped bool) {
+	args := []string{"create-service-broker", serviceBrokerName, "user", "pass", mitsConfig.Minibroker.API.Endpoint}
+	if spaceScoped {
+		args = append(args, "--space-scoped")
+	}
+	Expect(
+		cf.Cf(args...).
+			Wait(testSetup.ShortTimeout()),
+	).To(Exit(0))
+	if spaceScoped {
+		return
+	}
+
+	for _, testConfig := range mitsConfig.TestConfigs() {
+		if testConfig.Enabled {
+			Expect(
+				cf.Cf(
+					"enable-service-access", testConfig.Class,
+					"-p", testConfig.Plan,
+					"-b", serviceBrokerName,
+				).Wait(testSetup.ShortTimeout()),
+			).To(Exit(0))
+		}
+	}
+}
+
+func deleteServiceBroker() {
+	Expect(
+		cf.Cf("delete-service-broker", serviceBrokerName, "-f").
+			Wait(testSetup.ShortTimeout()),
+	).To(Exit(0))
+}
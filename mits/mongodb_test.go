@@ -29,6 +29,9 @@ var _ = Describe("MongoDB", func() {
 		if !mitsConfig.Tests.MongoDB.Enabled {
 			Skip("All MongoDB tests are disabled")
 		}
+		if mitsConfig.Upgrade.Enabled {
+			Skip("Only the upgrade scenario runs when enabled")
+		}
 	})
 
 	Context("Without overrideParams set", func() {
@@ -41,14 +44,11 @@ var _ = Describe("MongoDB", func() {
 		It("should deploy and connect WITH extra provisioning parameters", func() {
 			mits.SimpleAppAndService(
 				testSetup,
+				mitsConfig,
 				mitsConfig.Tests.MongoDB,
-				mitsConfig.Timeouts,
 				serviceBrokerName,
-				"assets/mongodbapp",
-				map[string]interface{}{
-					"mongodbDatabase": generator.PrefixedRandomName(mitsConfig.Tests.MongoDB.Class, "db"),
-					"mongodbUsername": generator.PrefixedRandomName(mitsConfig.Tests.MongoDB.Class, "user"),
-				},
+				"mongodbapp",
+				mongodbParams(),
 			)
 		})
 	})
@@ -63,12 +63,20 @@ var _ = Describe("MongoDB", func() {
 		It("should deploy and connect WITHOUT extra provisioning parameters", func() {
 			mits.SimpleAppAndService(
 				testSetup,
+				mitsConfig,
 				mitsConfig.Tests.MongoDB,
-				mitsConfig.Timeouts,
 				serviceBrokerName,
-				"assets/mongodbapp",
+				"mongodbapp",
 				nil,
 			)
 		})
 	})
 })
+
+// mongodbParams returns the extra provisioning parameters used for MongoDB.
+func mongodbParams() map[string]interface{} {
+	return map[string]interface{}{
+		"mongodbDatabase": generator.PrefixedRandomName(mitsConfig.Tests.MongoDB.Class, "db"),
+		"mongodbUsername": generator.PrefixedRandomName(mitsConfig.Tests.MongoDB.Class, "user"),
+	}
+}
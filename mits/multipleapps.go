@@ -0,0 +1,99 @@
+/*
+   Copyright 2020 SUSE
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package mits
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gexec"
+
+	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/generator"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/workflowhelpers"
+
+	"github.com/SUSE/minibroker-integration-tests/mits/config"
+)
+
+// MultipleAppsAndService asserts that appCount apps can be bound to the same service instance at
+// once, each seeding and verifying its own value. The first app is then unbound and the others
+// must keep working. When the testConfig expects per-binding credentials, each binding must be
+// issued distinct credentials.
+func MultipleAppsAndService(
+	testSetup *workflowhelpers.ReproducibleTestSuiteSetup,
+	mitsConfig *config.Config,
+	serviceBrokerName string,
+	c Case,
+	appCount int,
+) {
+	timeouts := mitsConfig.Timeouts.Merge(c.TestConfig.Timeouts)
+	appNames := make([]string, appCount)
+	for i := range appNames {
+		appNames[i] = generator.PrefixedRandomName(c.TestConfig.Class, "app")
+	}
+
+	params := config.MergeParams(c.Params, c.TestConfig.Params)
+	service, cleanup := provisionAndBind(testSetup, mitsConfig, serviceBrokerName, c.TestConfig, params, c.Asset, pushAsset, appNames...)
+	defer cleanup()
+
+	credentials := make([]map[string]interface{}, appCount)
+	for i, appName := range appNames {
+		By(fmt.Sprintf("seeding a distinct value from app %d", i))
+		Expect(
+			cf.Cf("set-env", appName, "SEED_MODE", "write").
+				Wait(testSetup.ShortTimeout()),
+		).To(Exit(0))
+		Expect(
+			cf.Cf("set-env", appName, "SEED_VALUE", generator.PrefixedRandomName("mits", "seed")).
+				Wait(testSetup.ShortTimeout()),
+		).To(Exit(0))
+
+		binding, err := service.Binding(appName, testSetup.ShortTimeout())
+		Expect(err).NotTo(HaveOccurred())
+		credentials[i], err = service.BindingCredentials(binding, testSetup.ShortTimeout())
+		Expect(err).NotTo(HaveOccurred())
+
+		By(fmt.Sprintf("starting app %d", i))
+		Expect(
+			cf.Cf("start", appName).
+				Wait(timeouts.CFStart),
+		).To(Exit(0))
+	}
+
+	if c.TestConfig.PerBindingCredentials {
+		By("asserting each binding was issued distinct credentials")
+		for i := range credentials {
+			for j := i + 1; j < len(credentials); j++ {
+				Expect(credentials[i]).NotTo(Equal(credentials[j]), "apps %d and %d were issued the same credentials", i, j)
+			}
+		}
+	}
+
+	By("unbinding the service instance from app 0")
+	err := service.Unbind(appNames[0], testSetup.ShortTimeout())
+	Expect(err).NotTo(HaveOccurred())
+
+	client := newAppClient(testSetup.ShortTimeout())
+	for i, appName := range appNames[1:] {
+		By(fmt.Sprintf("asserting app %d still reads its own value", i+1))
+		appURL, err := AppURL(appName, GinkgoWriter, testSetup.ShortTimeout())
+		Expect(err).NotTo(HaveOccurred())
+		_, err = probeWorkload(client, appURL+"/workload")
+		Expect(err).NotTo(HaveOccurred())
+	}
+}
@@ -0,0 +1,28 @@
+/*
+   Copyright 2020 SUSE
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package mits_test
+
+import (
+	"github.com/SUSE/minibroker-integration-tests/mits"
+	"github.com/SUSE/minibroker-integration-tests/mits/config"
+)
+
+var _ = describeScenario("Multiple apps", func(scenarios config.Scenarios) bool { return scenarios.MultipleApps },
+	"should bind multiple apps to the same %s instance",
+	func(serviceTest serviceTest) {
+		mits.MultipleAppsAndService(testSetup, mitsConfig, serviceBrokerName, serviceTest.mitsCase(), 2)
+	})
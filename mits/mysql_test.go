@@ -29,6 +29,9 @@ var _ = Describe("MySQL", func() {
 		if !mitsConfig.Tests.MySQL.Enabled {
 			Skip("All MySQL tests are disabled")
 		}
+		if mitsConfig.Upgrade.Enabled {
+			Skip("Only the upgrade scenario runs when enabled")
+		}
 	})
 
 	Context("Without overrideParams set", func() {
@@ -41,14 +44,11 @@ var _ = Describe("MySQL", func() {
 		It("should deploy and connect WITH extra provisioning parameters", func() {
 			mits.SimpleAppAndService(
 				testSetup,
+				mitsConfig,
 				mitsConfig.Tests.MySQL,
-				mitsConfig.Timeouts,
 				serviceBrokerName,
-				"assets/mysqlapp",
-				map[string]interface{}{
-					"mysqlDatabase": generator.PrefixedRandomName(mitsConfig.Tests.MySQL.Class, "db"),
-					"mysqlUser":     generator.PrefixedRandomName(mitsConfig.Tests.MySQL.Class, "user"),
-				},
+				"mysqlapp",
+				mysqlParams(),
 			)
 		})
 	})
@@ -63,12 +63,20 @@ var _ = Describe("MySQL", func() {
 		It("should deploy and connect WITHOUT extra provisioning parameters", func() {
 			mits.SimpleAppAndService(
 				testSetup,
+				mitsConfig,
 				mitsConfig.Tests.MySQL,
-				mitsConfig.Timeouts,
 				serviceBrokerName,
-				"assets/mysqlapp",
+				"mysqlapp",
 				nil,
 			)
 		})
 	})
 })
+
+// mysqlParams returns the extra provisioning parameters used for MySQL.
+func mysqlParams() map[string]interface{} {
+	return map[string]interface{}{
+		"mysqlDatabase": generator.PrefixedRandomName(mitsConfig.Tests.MySQL.Class, "db"),
+		"mysqlUser":     generator.PrefixedRandomName(mitsConfig.Tests.MySQL.Class, "user"),
+	}
+}
@@ -29,6 +29,9 @@ var _ = Describe("PostgreSQL", func() {
 		if !mitsConfig.Tests.PostgreSQL.Enabled {
 			Skip("All PostgreSQL tests are disabled")
 		}
+		if mitsConfig.Upgrade.Enabled {
+			Skip("Only the upgrade scenario runs when enabled")
+		}
 	})
 
 	Context("Without overrideParams set", func() {
@@ -41,14 +44,11 @@ var _ = Describe("PostgreSQL", func() {
 		It("should deploy and connect WITH extra provisioning parameters", func() {
 			mits.SimpleAppAndService(
 				testSetup,
+				mitsConfig,
 				mitsConfig.Tests.PostgreSQL,
-				mitsConfig.Timeouts,
 				serviceBrokerName,
-				"assets/postgresqlapp",
-				map[string]interface{}{
-					"postgresqlDatabase": generator.PrefixedRandomName(mitsConfig.Tests.PostgreSQL.Class, "db"),
-					"postgresqlUsername": generator.PrefixedRandomName(mitsConfig.Tests.PostgreSQL.Class, "user"),
-				},
+				"postgresqlapp",
+				postgresqlParams(),
 			)
 		})
 	})
@@ -63,12 +63,20 @@ var _ = Describe("PostgreSQL", func() {
 		It("should deploy and connect WITHOUT extra provisioning parameters", func() {
 			mits.SimpleAppAndService(
 				testSetup,
+				mitsConfig,
 				mitsConfig.Tests.PostgreSQL,
-				mitsConfig.Timeouts,
 				serviceBrokerName,
-				"assets/postgresqlapp",
+				"postgresqlapp",
 				nil,
 			)
 		})
 	})
 })
+
+// postgresqlParams returns the extra provisioning parameters used for PostgreSQL.
+func postgresqlParams() map[string]interface{} {
+	return map[string]interface{}{
+		"postgresqlDatabase": generator.PrefixedRandomName(mitsConfig.Tests.PostgreSQL.Class, "db"),
+		"postgresqlUsername": generator.PrefixedRandomName(mitsConfig.Tests.PostgreSQL.Class, "user"),
+	}
+}
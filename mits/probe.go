@@ -0,0 +1,81 @@
+/*
+   Copyright 2020 SUSE
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package mits
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gexec"
+
+	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/workflowhelpers"
+
+	"github.com/SUSE/minibroker-integration-tests/mits/config"
+	"github.com/SUSE/minibroker-integration-tests/mits/probe"
+)
+
+// DescribeCatalogProbe builds a spec for each plan selected from the catalog, running a
+// connectivity smoke test with the probeapp. It must be called before the specs run, once the
+// config is loaded and the plans are selected.
+func DescribeCatalogProbe(plans []probe.Plan, suite Suite) bool {
+	return Describe("Catalog probe", func() {
+		BeforeEach(func() {
+			if _, mitsConfig, _ := suite(); mitsConfig.Upgrade.Enabled {
+				Skip("Only the upgrade scenario runs when enabled")
+			}
+		})
+
+		for _, plan := range plans {
+			plan := plan
+
+			It("should connect to the "+plan.Class+" plan "+plan.Name, func() {
+				testSetup, mitsConfig, serviceBrokerName := suite()
+				ProbePlan(testSetup, mitsConfig, serviceBrokerName, plan)
+			})
+		}
+	})
+}
+
+// ProbePlan enables the service access to the plan as the admin user, since the probed plans
+// aren't enabled when the service broker is registered, and runs the simple scenario against it
+// with the probeapp.
+func ProbePlan(
+	testSetup *workflowhelpers.ReproducibleTestSuiteSetup,
+	mitsConfig *config.Config,
+	serviceBrokerName string,
+	plan probe.Plan,
+) {
+	testConfig := config.TestConfig{
+		Enabled: true,
+		Class:   plan.Class,
+		Plan:    plan.Name,
+	}
+
+	By("enabling the service access to the " + testConfig.Class + " plan " + testConfig.Plan)
+	workflowhelpers.AsUser(testSetup.AdminUserContext(), testSetup.ShortTimeout(), func() {
+		Expect(
+			cf.Cf(
+				"enable-service-access", testConfig.Class,
+				"-p", testConfig.Plan,
+				"-b", serviceBrokerName,
+			).Wait(testSetup.ShortTimeout()),
+		).To(Exit(0))
+	})
+
+	By("probing the " + testConfig.Class + " plan " + testConfig.Plan)
+	SimpleAppAndService(testSetup, mitsConfig, testConfig, serviceBrokerName, "probeapp", nil)
+}
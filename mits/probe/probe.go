@@ -0,0 +1,145 @@
+/*
+   Copyright 2020 SUSE
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package probe selects the plans probed by the catalog probe.
+package probe
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SUSE/minibroker-integration-tests/mits/config"
+)
+
+// Plan identifies a plan of a service class.
+type Plan struct {
+	Class string
+	Name  string
+}
+
+// FetchCatalog fetches the plans from the catalog of the service broker at endpoint using the Open
+// Service Broker API. Unlike the CF API, it can be used before the service broker is registered.
+func FetchCatalog(endpoint string, timeout time.Duration) ([]Plan, error) {
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(endpoint, "/")+"/v2/catalog", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch catalog: %w", err)
+	}
+	req.Header.Set("X-Broker-API-Version", "2.13")
+	client := http.Client{Timeout: timeout}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch catalog: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch catalog: unexpected status %s", res.Status)
+	}
+
+	var catalog struct {
+		Services []struct {
+			Name  string `json:"name"`
+			Plans []struct {
+				Name string `json:"name"`
+			} `json:"plans"`
+		} `json:"services"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&catalog); err != nil {
+		return nil, fmt.Errorf("failed to fetch catalog: %w", err)
+	}
+	var plans []Plan
+	for _, service := range catalog.Services {
+		for _, plan := range service.Plans {
+			plans = append(plans, Plan{Class: service.Name, Name: plan.Name})
+		}
+	}
+	return plans, nil
+}
+
+// Select returns the plans to probe, sorted by class. The plans listed by the probe config for a
+// class are probed even if the class is covered by an enabled test. For the other classes in the
+// catalog that aren't covered by an enabled test, only the newest plan is probed. When the probe
+// config restricts the classes, the classes not listed are left out.
+func Select(catalog []Plan, mitsConfig *config.Config) []Plan {
+	covered := make(map[string]bool)
+	for _, testConfig := range mitsConfig.TestConfigs() {
+		if testConfig.Enabled {
+			covered[testConfig.Class] = true
+		}
+	}
+	listed := make(map[string]bool)
+	for _, class := range mitsConfig.Probe.Classes {
+		listed[class] = true
+	}
+	included := func(class string) bool {
+		return len(listed) == 0 || listed[class]
+	}
+
+	newest := make(map[string]string)
+	for _, plan := range catalog {
+		if covered[plan.Class] || !included(plan.Class) {
+			continue
+		}
+		if _, ok := mitsConfig.Probe.Plans[plan.Class]; ok {
+			continue
+		}
+		if name, ok := newest[plan.Class]; !ok || newerVersion(plan.Name, name) {
+			newest[plan.Class] = plan.Name
+		}
+	}
+
+	var selected []Plan
+	for class, names := range mitsConfig.Probe.Plans {
+		if !included(class) {
+			continue
+		}
+		for _, name := range names {
+			selected = append(selected, Plan{Class: class, Name: name})
+		}
+	}
+	for class, name := range newest {
+		selected = append(selected, Plan{Class: class, Name: name})
+	}
+	// The plans of each class were appended together, so the stable sort keeps their listed order.
+	sort.SliceStable(selected, func(i, j int) bool {
+		return selected[i].Class < selected[j].Class
+	})
+	return selected
+}
+
+// newerVersion returns whether the plan named a is for a newer version than the plan named b.
+// Minibroker names the plans after the chart app versions, with dashes instead of dots, e.g. 5-0-7.
+// The numeric components are compared as numbers and the others as strings.
+func newerVersion(a string, b string) bool {
+	aParts := strings.Split(a, "-")
+	bParts := strings.Split(b, "-")
+	for i := 0; i < len(aParts) && i < len(bParts); i++ {
+		if aParts[i] == bParts[i] {
+			continue
+		}
+		aNumber, aErr := strconv.Atoi(aParts[i])
+		bNumber, bErr := strconv.Atoi(bParts[i])
+		if aErr == nil && bErr == nil {
+			return aNumber > bNumber
+		}
+		return aParts[i] > bParts[i]
+	}
+	return len(aParts) > len(bParts)
+}
@@ -0,0 +1,136 @@
+/*
+   Copyright 2020 SUSE
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package probe_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/SUSE/minibroker-integration-tests/mits/config"
+	"github.com/SUSE/minibroker-integration-tests/mits/probe"
+)
+
+var _ = Describe("FetchCatalog", func() {
+	It("should fetch the plans of every service class", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer GinkgoRecover()
+			Expect(r.URL.Path).To(Equal("/v2/catalog"))
+			Expect(r.Header.Get("X-Broker-API-Version")).To(Equal("2.13"))
+			w.Write([]byte(`{"services": [
+				{"name": "redis", "plans": [{"name": "4-0-10"}, {"name": "5-0-7"}]},
+				{"name": "memcached", "plans": [{"name": "1-5-20"}]}
+			]}`))
+		}))
+		defer server.Close()
+
+		plans, err := probe.FetchCatalog(server.URL+"/", time.Minute)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(plans).To(Equal([]probe.Plan{
+			{Class: "redis", Name: "4-0-10"},
+			{Class: "redis", Name: "5-0-7"},
+			{Class: "memcached", Name: "1-5-20"},
+		}))
+	})
+
+	It("should fail when the catalog can't be fetched", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+		}))
+		defer server.Close()
+
+		_, err := probe.FetchCatalog(server.URL, time.Minute)
+		Expect(err).To(MatchError(ContainSubstring("412")))
+	})
+})
+
+var _ = Describe("Select", func() {
+	catalog := []probe.Plan{
+		{Class: "redis", Name: "5-0-7"},
+		{Class: "redis", Name: "10-0-1"},
+		{Class: "redis", Name: "9-12-0"},
+		{Class: "memcached", Name: "1-5-20"},
+		{Class: "memcached", Name: "1-5-20-debian"},
+		{Class: "etcd", Name: "3-4-9"},
+	}
+
+	DescribeTable("should select the probed plans",
+		func(setup func(c *config.Config), expected []probe.Plan) {
+			var c config.Config
+			setup(&c)
+			Expect(probe.Select(catalog, &c)).To(Equal(expected))
+		},
+		Entry("the newest plan of each class",
+			func(c *config.Config) {},
+			[]probe.Plan{
+				{Class: "etcd", Name: "3-4-9"},
+				{Class: "memcached", Name: "1-5-20-debian"},
+				{Class: "redis", Name: "10-0-1"},
+			},
+		),
+		Entry("without the classes covered by an enabled test",
+			func(c *config.Config) {
+				c.Tests.Redis = config.TestConfig{Enabled: true, Class: "redis"}
+				c.Tests.MySQL = config.TestConfig{Enabled: false, Class: "etcd"}
+			},
+			[]probe.Plan{
+				{Class: "etcd", Name: "3-4-9"},
+				{Class: "memcached", Name: "1-5-20-debian"},
+			},
+		),
+		Entry("only the listed classes",
+			func(c *config.Config) {
+				c.Probe.Classes = []string{"etcd", "redis"}
+			},
+			[]probe.Plan{
+				{Class: "etcd", Name: "3-4-9"},
+				{Class: "redis", Name: "10-0-1"},
+			},
+		),
+		Entry("the listed plans in their order, even for a covered class",
+			func(c *config.Config) {
+				c.Tests.Redis = config.TestConfig{Enabled: true, Class: "redis"}
+				c.Probe.Plans = map[string][]string{
+					"redis": {"9-12-0", "5-0-7"},
+					"etcd":  {"3-4-9"},
+				}
+			},
+			[]probe.Plan{
+				{Class: "etcd", Name: "3-4-9"},
+				{Class: "memcached", Name: "1-5-20-debian"},
+				{Class: "redis", Name: "9-12-0"},
+				{Class: "redis", Name: "5-0-7"},
+			},
+		),
+		Entry("the listed plans of the listed classes only",
+			func(c *config.Config) {
+				c.Probe.Classes = []string{"memcached"}
+				c.Probe.Plans = map[string][]string{
+					"memcached": {"1-5-20"},
+					"redis":     {"5-0-7"},
+				}
+			},
+			[]probe.Plan{
+				{Class: "memcached", Name: "1-5-20"},
+			},
+		),
+	)
+})
@@ -29,6 +29,9 @@ var _ = Describe("RabbitMQ", func() {
 		if !mitsConfig.Tests.RabbitMQ.Enabled {
 			Skip("All RabbitMQ tests are disabled")
 		}
+		if mitsConfig.Upgrade.Enabled {
+			Skip("Only the upgrade scenario runs when enabled")
+		}
 	})
 
 	Context("Without overrideParams set", func() {
@@ -41,15 +44,11 @@ var _ = Describe("RabbitMQ", func() {
 		It("should deploy and connect WITH extra provisioning parameters", func() {
 			mits.SimpleAppAndService(
 				testSetup,
+				mitsConfig,
 				mitsConfig.Tests.RabbitMQ,
-				mitsConfig.Timeouts,
 				serviceBrokerName,
-				"assets/rabbitmqapp",
-				map[string]interface{}{
-					"rabbitmq": map[string]interface{}{
-						"username": generator.PrefixedRandomName(mitsConfig.Tests.RabbitMQ.Class, "user"),
-					},
-				},
+				"rabbitmqapp",
+				rabbitmqParams(),
 			)
 		})
 	})
@@ -64,12 +63,21 @@ var _ = Describe("RabbitMQ", func() {
 		It("should deploy and connect WITHOUT extra provisioning parameters", func() {
 			mits.SimpleAppAndService(
 				testSetup,
+				mitsConfig,
 				mitsConfig.Tests.RabbitMQ,
-				mitsConfig.Timeouts,
 				serviceBrokerName,
-				"assets/rabbitmqapp",
+				"rabbitmqapp",
 				nil,
 			)
 		})
 	})
 })
+
+// rabbitmqParams returns the extra provisioning parameters used for RabbitMQ.
+func rabbitmqParams() map[string]interface{} {
+	return map[string]interface{}{
+		"rabbitmq": map[string]interface{}{
+			"username": generator.PrefixedRandomName(mitsConfig.Tests.RabbitMQ.Class, "user"),
+		},
+	}
+}
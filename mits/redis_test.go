@@ -27,6 +27,9 @@ var _ = Describe("Redis", func() {
 		if !mitsConfig.Tests.Redis.Enabled {
 			Skip("All Redis tests are disabled")
 		}
+		if mitsConfig.Upgrade.Enabled {
+			Skip("Only the upgrade scenario runs when enabled")
+		}
 	})
 
 	Context("Without overrideParams set", func() {
@@ -39,15 +42,22 @@ var _ = Describe("Redis", func() {
 		It("should deploy and connect WITH extra provisioning parameters", func() {
 			mits.SimpleAppAndService(
 				testSetup,
+				mitsConfig,
+				mitsConfig.Tests.Redis,
+				serviceBrokerName,
+				"redisapp",
+				redisParams(),
+			)
+		})
+
+		It("should deploy and connect WITH clustering enabled", func() {
+			mits.SimpleAppAndService(
+				testSetup,
+				mitsConfig,
 				mitsConfig.Tests.Redis,
-				mitsConfig.Timeouts,
 				serviceBrokerName,
-				"assets/redisapp",
-				map[string]interface{}{
-					"cluster": map[string]interface{}{
-						"enabled": false,
-					},
-				},
+				"redisapp",
+				redisClusterParams(),
 			)
 		})
 	})
@@ -62,12 +72,31 @@ var _ = Describe("Redis", func() {
 		It("should deploy and connect WITHOUT extra provisioning parameters", func() {
 			mits.SimpleAppAndService(
 				testSetup,
+				mitsConfig,
 				mitsConfig.Tests.Redis,
-				mitsConfig.Timeouts,
 				serviceBrokerName,
-				"assets/redisapp",
+				"redisapp",
 				nil,
 			)
 		})
 	})
 })
+
+// redisParams returns the extra provisioning parameters used for Redis.
+func redisParams() map[string]interface{} {
+	return map[string]interface{}{
+		"cluster": map[string]interface{}{
+			"enabled": false,
+		},
+	}
+}
+
+// redisClusterParams returns the provisioning parameters used for Redis with clustering enabled.
+// The app detects the resulting topology from the credentials and the server.
+func redisClusterParams() map[string]interface{} {
+	return map[string]interface{}{
+		"cluster": map[string]interface{}{
+			"enabled": true,
+		},
+	}
+}
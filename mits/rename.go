@@ -0,0 +1,103 @@
+/*
+   Copyright 2020 SUSE
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package mits
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gexec"
+
+	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/generator"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/workflowhelpers"
+
+	"github.com/SUSE/minibroker-integration-tests/mits/config"
+)
+
+// RenameAndMetadata asserts that a bound service instance can be labeled, annotated and renamed.
+// The binding, service key and metadata must survive the rename, and the app must look the
+// service instance up by its new name once restaged. The app is pushed rather than created from the
+// droplet cache, since restaging requires its package.
+func RenameAndMetadata(
+	testSetup *workflowhelpers.ReproducibleTestSuiteSetup,
+	mitsConfig *config.Config,
+	serviceBrokerName string,
+	c Case,
+) {
+	timeouts := mitsConfig.Timeouts.Merge(c.TestConfig.Timeouts)
+	appName := generator.PrefixedRandomName(c.TestConfig.Class, "app")
+
+	params := config.MergeParams(c.Params, c.TestConfig.Params)
+	service, cleanup := provisionAndBind(testSetup, mitsConfig, serviceBrokerName, c.TestConfig, params, c.Asset, pushAssetPackage, appName)
+	defer cleanup()
+
+	By("capturing the binding")
+	binding, err := service.Binding(appName, testSetup.ShortTimeout())
+	Expect(err).NotTo(HaveOccurred())
+
+	By("creating a service key")
+	key, err := service.CreateKey(generator.PrefixedRandomName(c.TestConfig.Class, "key"), nil, testSetup.ShortTimeout())
+	Expect(err).NotTo(HaveOccurred())
+	defer service.DeleteKey(key, testSetup.ShortTimeout())
+
+	By("starting the app")
+	Expect(
+		cf.Cf("start", appName).
+			Wait(timeouts.CFStart),
+	).To(Exit(0))
+
+	By("labeling and annotating the service instance")
+	labels := map[string]string{"mits.suse.com/class": c.TestConfig.Class}
+	err = service.SetLabels(labels, testSetup.ShortTimeout())
+	Expect(err).NotTo(HaveOccurred())
+	annotations := map[string]string{"mits.suse.com/plan": c.TestConfig.Plan}
+	err = service.SetAnnotations(annotations, testSetup.ShortTimeout())
+	Expect(err).NotTo(HaveOccurred())
+
+	By("renaming the service instance")
+	newServiceName := generator.PrefixedRandomName(c.TestConfig.Class, "renamed")
+	err = service.Rename(newServiceName, testSetup.ShortTimeout())
+	Expect(err).NotTo(HaveOccurred())
+
+	By("asserting the binding, service key and metadata survived the rename")
+	renamedBinding, err := service.Binding(appName, testSetup.ShortTimeout())
+	Expect(err).NotTo(HaveOccurred())
+	Expect(renamedBinding.GUID).To(Equal(binding.GUID))
+	keys, err := service.Keys(testSetup.ShortTimeout())
+	Expect(err).NotTo(HaveOccurred())
+	Expect(keys).To(ContainElement(*key))
+	metadata, err := service.Metadata(testSetup.ShortTimeout())
+	Expect(err).NotTo(HaveOccurred())
+	Expect(metadata.Labels).To(Equal(labels))
+	Expect(metadata.Annotations).To(Equal(annotations))
+
+	By("asserting the app no longer finds the service instance by its old name")
+	Expect(
+		cf.Cf("restage", appName).
+			Wait(timeouts.CFPush + timeouts.CFStart),
+	).NotTo(Exit(0))
+
+	By("restaging the app pointing to the new service instance name")
+	Expect(
+		cf.Cf("set-env", appName, "SERVICE_NAME", newServiceName).
+			Wait(testSetup.ShortTimeout()),
+	).To(Exit(0))
+	Expect(
+		cf.Cf("restage", appName).
+			Wait(timeouts.CFPush + timeouts.CFStart),
+	).To(Exit(0))
+}
@@ -0,0 +1,82 @@
+/*
+   Copyright 2020 SUSE
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package mits
+
+import (
+	"encoding/json"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gexec"
+
+	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/generator"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/workflowhelpers"
+
+	"github.com/SUSE/minibroker-integration-tests/mits/config"
+)
+
+// CredentialRevocation captures the credentials of a binding and unbinds the app. The app is then
+// restarted unbound, with the captured credentials set in SERVICE_CREDENTIALS, to probe whether
+// the service still accepts them. It returns whether the unbind revoked the credentials, which is
+// also reported to the test output.
+func CredentialRevocation(
+	testSetup *workflowhelpers.ReproducibleTestSuiteSetup,
+	mitsConfig *config.Config,
+	serviceBrokerName string,
+	c Case,
+) bool {
+	timeouts := mitsConfig.Timeouts.Merge(c.TestConfig.Timeouts)
+	appName := generator.PrefixedRandomName(c.TestConfig.Class, "app")
+
+	params := config.MergeParams(c.Params, c.TestConfig.Params)
+	service, cleanup := provisionAndBind(testSetup, mitsConfig, serviceBrokerName, c.TestConfig, params, c.Asset, pushAsset, appName)
+	defer cleanup()
+
+	By("capturing the binding credentials")
+	binding, err := service.Binding(appName, testSetup.ShortTimeout())
+	Expect(err).NotTo(HaveOccurred())
+	credentials, err := service.BindingCredentials(binding, testSetup.ShortTimeout())
+	Expect(err).NotTo(HaveOccurred())
+	credentialsBytes, err := json.Marshal(credentials)
+	Expect(err).NotTo(HaveOccurred())
+
+	By("starting the app")
+	Expect(
+		cf.Cf("start", appName).
+			Wait(timeouts.CFStart),
+	).To(Exit(0))
+
+	By("unbinding the service instance from the app")
+	err = service.Unbind(appName, testSetup.ShortTimeout())
+	Expect(err).NotTo(HaveOccurred())
+
+	By("restarting the app with the credentials of the deleted binding")
+	Expect(
+		cf.Cf("set-env", appName, "SERVICE_CREDENTIALS", string(credentialsBytes)).
+			Wait(testSetup.ShortTimeout()),
+	).To(Exit(0))
+	revoked := cf.Cf("restart", appName).Wait(timeouts.CFStart).ExitCode() != 0
+
+	if revoked {
+		fmt.Fprintf(GinkgoWriter, "Unbinding %s from %s revoked the binding credentials\n", service.name, appName)
+	} else {
+		fmt.Fprintf(GinkgoWriter, "Unbinding %s from %s is a no-op: the binding credentials still work\n", service.name, appName)
+	}
+	return revoked
+}
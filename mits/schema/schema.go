@@ -0,0 +1,57 @@
+/*
+   Copyright 2020 SUSE
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package schema validates the parameters schemas advertised by the service plans, and the
+// parameters sent to them.
+package schema
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// Validate checks that a parameters schema advertised by a plan is a well-formed JSON Schema.
+func Validate(schema map[string]interface{}) error {
+	if _, err := gojsonschema.NewSchema(gojsonschema.NewGoLoader(schema)); err != nil {
+		return fmt.Errorf("malformed schema: %w", err)
+	}
+	return nil
+}
+
+// ValidateParams validates the params against a parameters schema advertised by a plan. All the
+// violations are reported at once. Plans that don't advertise a schema accept any params.
+func ValidateParams(schema map[string]interface{}, params map[string]interface{}) error {
+	if len(schema) == 0 {
+		return nil
+	}
+	if params == nil {
+		params = map[string]interface{}{}
+	}
+	result, err := gojsonschema.Validate(gojsonschema.NewGoLoader(schema), gojsonschema.NewGoLoader(params))
+	if err != nil {
+		return fmt.Errorf("failed to validate params: %w", err)
+	}
+	if result.Valid() {
+		return nil
+	}
+	violations := make([]string, 0, len(result.Errors()))
+	for _, violation := range result.Errors() {
+		violations = append(violations, violation.String())
+	}
+	return fmt.Errorf("params don't match the schema:\n  - %s", strings.Join(violations, "\n  - "))
+}
@@ -0,0 +1,78 @@
+/*
+   Copyright 2020 SUSE
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package schema_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/SUSE/minibroker-integration-tests/mits/schema"
+)
+
+var _ = Describe("Schema", func() {
+	planSchema := map[string]interface{}{
+		"$schema": "http://json-schema.org/draft-04/schema#",
+		"type":    "object",
+		"properties": map[string]interface{}{
+			"persistence": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"enabled": map[string]interface{}{"type": "boolean"},
+				},
+			},
+			"replicas": map[string]interface{}{"type": "integer", "minimum": 1},
+		},
+	}
+
+	Describe("Validate", func() {
+		It("should accept a well-formed schema", func() {
+			Expect(schema.Validate(planSchema)).To(Succeed())
+		})
+
+		It("should reject a malformed schema", func() {
+			err := schema.Validate(map[string]interface{}{"type": 42})
+			Expect(err).To(MatchError(ContainSubstring("malformed schema")))
+		})
+	})
+
+	Describe("ValidateParams", func() {
+		It("should accept valid params", func() {
+			params := map[string]interface{}{
+				"persistence": map[string]interface{}{"enabled": false},
+				"replicas":    2,
+			}
+			Expect(schema.ValidateParams(planSchema, params)).To(Succeed())
+			Expect(schema.ValidateParams(planSchema, nil)).To(Succeed())
+		})
+
+		It("should report every violation of invalid params", func() {
+			params := map[string]interface{}{
+				"persistence": map[string]interface{}{"enabled": "no"},
+				"replicas":    0,
+			}
+			err := schema.ValidateParams(planSchema, params)
+			Expect(err).To(MatchError(ContainSubstring("persistence.enabled")))
+			Expect(err).To(MatchError(ContainSubstring("replicas")))
+		})
+
+		It("should accept any params for a plan without a schema", func() {
+			params := map[string]interface{}{"anything": true}
+			Expect(schema.ValidateParams(nil, params)).To(Succeed())
+			Expect(schema.ValidateParams(map[string]interface{}{}, params)).To(Succeed())
+		})
+	})
+})
@@ -0,0 +1,265 @@
+/*
+   Copyright 2020 SUSE
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+/*
+Package securitygroup builds the CF application security group rules that allow apps to reach a
+service instance, based on the service instance credentials.
+*/
+package securitygroup
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Rule is a CF application security group rule.
+type Rule struct {
+	Protocol    string `json:"protocol"`
+	Destination string `json:"destination"`
+	Ports       string `json:"ports"`
+	Description string `json:"description,omitempty"`
+}
+
+// Endpoint is a host and port pair that apps need to reach.
+type Endpoint struct {
+	Host string
+	Port string
+}
+
+// Resolver resolves a host into its IP addresses. net.LookupIP satisfies it.
+type Resolver func(host string) ([]net.IP, error)
+
+// Builder builds security group rules for service endpoints.
+type Builder struct {
+	resolve Resolver
+}
+
+// NewBuilder instantiates a new Builder.
+func NewBuilder(resolve Resolver) *Builder {
+	return &Builder{resolve: resolve}
+}
+
+// Rules builds the TCP rules covering every address resolved for the endpoints. IPv4 addresses
+// are allowed as /32 and IPv6 addresses as /128, while CIDR hosts are used as destinations as is.
+// The ports of endpoints resolving to the same address are merged into a single rule.
+func (builder *Builder) Rules(endpoints []Endpoint, description string) ([]Rule, error) {
+	ports := make(map[string]map[string]struct{})
+	for _, endpoint := range endpoints {
+		destinations, err := builder.destinations(endpoint.Host)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build security group rules: %w", err)
+		}
+		for _, destination := range destinations {
+			if ports[destination] == nil {
+				ports[destination] = make(map[string]struct{})
+			}
+			ports[destination][endpoint.Port] = struct{}{}
+		}
+	}
+
+	rules := make([]Rule, 0, len(ports))
+	for destination, destinationPorts := range ports {
+		rules = append(rules, Rule{
+			Protocol:    "tcp",
+			Destination: destination,
+			Ports:       strings.Join(sortedPorts(destinationPorts), ","),
+			Description: description,
+		})
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Destination < rules[j].Destination })
+	return rules, nil
+}
+
+func (builder *Builder) destinations(host string) ([]string, error) {
+	if _, network, err := net.ParseCIDR(host); err == nil {
+		return []string{network.String()}, nil
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return []string{destination(ip)}, nil
+	}
+	ips, err := builder.resolve(host)
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no addresses found for %q", host)
+	}
+	destinations := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		destinations = append(destinations, destination(ip))
+	}
+	return destinations, nil
+}
+
+func destination(ip net.IP) string {
+	if ipv4 := ip.To4(); ipv4 != nil {
+		return ipv4.String() + "/32"
+	}
+	return ip.String() + "/128"
+}
+
+func sortedPorts(ports map[string]struct{}) []string {
+	sorted := make([]string, 0, len(ports))
+	for port := range ports {
+		sorted = append(sorted, port)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		a, _ := strconv.Atoi(sorted[i])
+		b, _ := strconv.Atoi(sorted[j])
+		return a < b
+	})
+	return sorted
+}
+
+// defaultPorts maps the URI schemes used by the Minibroker service classes to their default ports.
+var defaultPorts = map[string]string{
+	"amqp":       "5672",
+	"amqps":      "5671",
+	"mongodb":    "27017",
+	"mysql":      "3306",
+	"postgres":   "5432",
+	"postgresql": "5432",
+	"redis":      "6379",
+	"rediss":     "6379",
+}
+
+// Endpoints extracts the endpoints from the service instance credentials. It reads the host and
+// port fields as well as every host:port pair in the uri and uris fields, which may list several
+// comma-separated hosts, e.g. for replica sets. A host without a port field uses the port of the
+// same host in the uris, else the port of the first uri. Duplicate endpoints are only returned
+// once.
+func Endpoints(credentials map[string]interface{}) ([]Endpoint, error) {
+	var endpoints []Endpoint
+	seen := make(map[Endpoint]struct{})
+	add := func(endpoint Endpoint) {
+		if _, ok := seen[endpoint]; !ok {
+			seen[endpoint] = struct{}{}
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+
+	port, err := credentialsPort(credentials["port"])
+	if err != nil {
+		return nil, fmt.Errorf("failed to get endpoints from credentials: %w", err)
+	}
+	var uris []string
+	if uri, ok := credentials["uri"].(string); ok && uri != "" {
+		uris = append(uris, uri)
+	}
+	if list, ok := credentials["uris"].([]interface{}); ok {
+		for _, uri := range list {
+			if uri, ok := uri.(string); ok && uri != "" {
+				uris = append(uris, uri)
+			}
+		}
+	}
+	var uriEndpoints []Endpoint
+	for _, uri := range uris {
+		endpoints, err := URIEndpoints(uri, port)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get endpoints from credentials: %w", err)
+		}
+		uriEndpoints = append(uriEndpoints, endpoints...)
+	}
+
+	if host, ok := credentials["host"].(string); ok && host != "" {
+		hostPort := port
+		if hostPort == "" {
+			for _, endpoint := range uriEndpoints {
+				if endpoint.Host == host {
+					hostPort = endpoint.Port
+					break
+				}
+			}
+		}
+		if hostPort == "" && len(uriEndpoints) > 0 {
+			hostPort = uriEndpoints[0].Port
+		}
+		if hostPort == "" {
+			return nil, fmt.Errorf("failed to get endpoints from credentials: missing port for host %q", host)
+		}
+		add(Endpoint{Host: host, Port: hostPort})
+	}
+	for _, endpoint := range uriEndpoints {
+		add(endpoint)
+	}
+
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("failed to get endpoints from credentials: no host or uri found")
+	}
+	return endpoints, nil
+}
+
+// URIEndpoints parses the host:port pairs from a URI. The URI may list several comma-separated
+// hosts, which net/url is not able to parse. Hosts without a port use defaultPort or, if empty,
+// the default port for the URI scheme. The userinfo is stripped at the last '@' first, so a
+// password may hold unescaped '/', '?' or '#', while an '@' in the path or query must be escaped.
+func URIEndpoints(uri string, defaultPort string) ([]Endpoint, error) {
+	schemeSeparator := strings.Index(uri, "://")
+	if schemeSeparator < 0 {
+		return nil, fmt.Errorf("invalid URI %q: missing scheme", uri)
+	}
+	scheme := strings.ToLower(uri[:schemeSeparator])
+	authority := uri[schemeSeparator+len("://"):]
+	if i := strings.LastIndex(authority, "@"); i >= 0 {
+		authority = authority[i+1:]
+	}
+	if i := strings.IndexAny(authority, "/?#"); i >= 0 {
+		authority = authority[:i]
+	}
+	if defaultPort == "" {
+		defaultPort = defaultPorts[scheme]
+	}
+
+	var endpoints []Endpoint
+	for _, hostPort := range strings.Split(authority, ",") {
+		if hostPort == "" {
+			continue
+		}
+		host, port, err := net.SplitHostPort(hostPort)
+		if err != nil {
+			// The host has no port.
+			host = strings.TrimSuffix(strings.TrimPrefix(hostPort, "["), "]")
+			port = defaultPort
+		}
+		if port == "" {
+			return nil, fmt.Errorf("invalid URI %q: missing port for host %q", uri, host)
+		}
+		endpoints = append(endpoints, Endpoint{Host: host, Port: port})
+	}
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("invalid URI %q: missing host", uri)
+	}
+	return endpoints, nil
+}
+
+func credentialsPort(port interface{}) (string, error) {
+	switch port := port.(type) {
+	case nil:
+		return "", nil
+	case float64:
+		return strconv.Itoa(int(port)), nil
+	case int:
+		return strconv.Itoa(port), nil
+	case string:
+		return port, nil
+	default:
+		return "", fmt.Errorf("invalid port %v", port)
+	}
+}
@@ -97,13 +97,35 @@ func (service *Service) WaitForCreate(timeout time.Duration) error {
 	return service.waitForCondition(cond, timeout)
 }
 
-// WaitForDelete waits for the deletion of the service instance.
+// WaitForDelete waits for the deletion of the service instance. Since the service instance no
+// longer exists once deleted, it is looked up by guid on the v3 API instead of using cf service.
 func (service *Service) WaitForDelete(timeout time.Duration) error {
-	cond := conditions{
-		progress:  "delete in progress",
-		completed: "delete succeeded",
+	timeLimit := time.Now().Add(timeout)
+	for {
+		if time.Now().After(timeLimit) {
+			return fmt.Errorf("failed to wait for service instance deletion: timed out")
+		}
+
+		var instances struct {
+			Resources []struct {
+				LastOperation struct {
+					Type  string `json:"type"`
+					State string `json:"state"`
+				} `json:"last_operation"`
+			} `json:"resources"`
+		}
+		if err := cfCurl(service.stderr, timeout, &instances, "/v3/service_instances?guids="+service.guid); err != nil {
+			return fmt.Errorf("failed to wait for service instance deletion: %w", err)
+		}
+		if len(instances.Resources) == 0 {
+			return nil
+		}
+		lastOperation := instances.Resources[0].LastOperation
+		if lastOperation.Type == "delete" && lastOperation.State == "failed" {
+			return fmt.Errorf("failed to wait for service instance deletion: the service status is \"delete failed\"")
+		}
+		time.Sleep(time.Second)
 	}
-	return service.waitForCondition(cond, timeout)
 }
 
 func (service *Service) waitForCondition(cond conditions, timeout time.Duration) error {
@@ -156,54 +178,179 @@ func (service *Service) Credentials(timeout time.Duration) (map[string]interface
 		return service.credentials, nil
 	}
 
-	session := cf.Cf("create-service-key", service.name, serviceKey).Wait(timeout)
-	if session.ExitCode() != 0 {
-		return nil, fmt.Errorf("failed to get credentials for service instance: failed to create service key")
+	key, err := service.CreateKey(serviceKey, nil, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credentials for service instance: %w", err)
 	}
-
-	var guidBuilder strings.Builder
-	cmd := exec.Command("cf", "service-key", "--guid", service.name, serviceKey)
-	session, err := gexec.Start(cmd, &guidBuilder, service.stderr)
+	credentials, err := service.KeyCredentials(key, timeout)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get credentials for service instance: %w", err)
 	}
-	if exitCode := session.Wait(timeout).ExitCode(); exitCode != 0 {
-		return nil, fmt.Errorf("failed to get credentials for service instance: failed to get service key guid")
+
+	service.credentials = credentials
+	return service.credentials, nil
+}
+
+// Bind binds the service instance to an app, passing the optional binding name and parameters.
+func (service *Service) Bind(appName string, bindingName string, params map[string]interface{}, timeout time.Duration) error {
+	bindArgs := []string{"bind-service", appName, service.name}
+	if bindingName != "" {
+		bindArgs = append(bindArgs, "--binding-name", bindingName)
+	}
+	if params != nil {
+		paramsBytes, err := json.Marshal(params)
+		if err != nil {
+			return fmt.Errorf("failed to bind service instance: %w", err)
+		}
+		bindArgs = append(bindArgs, "-c", string(paramsBytes))
 	}
-	serviceKeyGUID := strings.TrimSpace(guidBuilder.String())
+	session := cf.Cf(bindArgs...).Wait(timeout)
+	if exitCode := session.ExitCode(); exitCode != 0 {
+		return fmt.Errorf("failed to bind service instance: cf bind-service %s %s exited with code %d", appName, service.name, exitCode)
+	}
+	return nil
+}
 
-	cmd = exec.Command("cf", "curl", "--fail", "/v2/service_keys/"+serviceKeyGUID)
-	pipeReader, pipeWriter := io.Pipe()
-	session, err = gexec.Start(cmd, pipeWriter, service.stderr)
+// Binding is a service binding between a service instance and an app.
+type Binding struct {
+	GUID string
+	Name string
+}
+
+// Binding fetches the binding between the service instance and an app.
+func (service *Service) Binding(appName string, timeout time.Duration) (*Binding, error) {
+	appGUID, err := cfGUID(service.stderr, timeout, "app", "--guid", appName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get credentials for service instance: %w", err)
+		return nil, fmt.Errorf("failed to get service binding: %w", err)
+	}
+	var bindings struct {
+		Resources []struct {
+			Metadata struct {
+				GUID string `json:"guid"`
+			} `json:"metadata"`
+			Entity struct {
+				Name string `json:"name"`
+			} `json:"entity"`
+		} `json:"resources"`
+	}
+	path := "/v2/service_bindings?q=service_instance_guid:" + service.guid + "&q=app_guid:" + appGUID
+	if err := cfCurl(service.stderr, timeout, &bindings, path); err != nil {
+		return nil, fmt.Errorf("failed to get service binding: %w", err)
 	}
-	go func() {
-		defer pipeWriter.Close()
-		session.Wait(timeout)
-	}()
+	if len(bindings.Resources) != 1 {
+		return nil, fmt.Errorf("failed to get service binding: found %d bindings to app %s", len(bindings.Resources), appName)
+	}
+	return &Binding{
+		GUID: bindings.Resources[0].Metadata.GUID,
+		Name: bindings.Resources[0].Entity.Name,
+	}, nil
+}
 
-	var body map[string]interface{}
-	decoder := json.NewDecoder(pipeReader)
-	if err := decoder.Decode(&body); err != nil {
-		return nil, fmt.Errorf("failed to get credentials for service instance: %w", err)
+// BindingCredentials fetches the credentials the service broker issued to a service binding.
+func (service *Service) BindingCredentials(binding *Binding, timeout time.Duration) (map[string]interface{}, error) {
+	var body struct {
+		Entity struct {
+			Credentials map[string]interface{} `json:"credentials"`
+		} `json:"entity"`
+	}
+	if err := cfCurl(service.stderr, timeout, &body, "/v2/service_bindings/"+binding.GUID); err != nil {
+		return nil, fmt.Errorf("failed to get service binding credentials: %w", err)
+	}
+	return body.Entity.Credentials, nil
+}
+
+// BindingParameters fetches the parameters of a service binding from the service broker. It
+// requires the service offering to declare the bindings_retrievable feature.
+func (service *Service) BindingParameters(binding *Binding, timeout time.Duration) (map[string]interface{}, error) {
+	var params map[string]interface{}
+	if err := cfCurl(service.stderr, timeout, &params, "/v2/service_bindings/"+binding.GUID+"/parameters"); err != nil {
+		return nil, fmt.Errorf("failed to get service binding parameters: %w", err)
 	}
+	return params, nil
+}
 
-	// cf curl exits with code 22 if an error is reported back.
-	// On success, it is returning non-zero codes, hence the unusual check below.
-	if exitCode := session.ExitCode(); exitCode == 22 {
-		return nil, fmt.Errorf("failed to get credentials for service instance: cf service-key %s %s exited with code %d", service.name, serviceKey, exitCode)
+// Rename renames the service instance.
+func (service *Service) Rename(newName string, timeout time.Duration) error {
+	session := cf.Cf("rename-service", service.name, newName).Wait(timeout)
+	if exitCode := session.ExitCode(); exitCode != 0 {
+		return fmt.Errorf("failed to rename service instance: cf rename-service %s %s exited with code %d", service.name, newName, exitCode)
 	}
+	service.name = newName
+	return nil
+}
 
-	service.credentials = body["entity"].(map[string]interface{})["credentials"].(map[string]interface{})
-	return service.credentials, nil
+// Metadata is the metadata of a service instance on the CF v3 API.
+type Metadata struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+// SetLabels sets the labels of the service instance. Labels set to an empty string are removed.
+func (service *Service) SetLabels(labels map[string]string, timeout time.Duration) error {
+	if err := service.patchMetadata("labels", labels, timeout); err != nil {
+		return fmt.Errorf("failed to set service instance labels: %w", err)
+	}
+	return nil
+}
+
+// SetAnnotations sets the annotations of the service instance. Annotations set to an empty string
+// are removed.
+func (service *Service) SetAnnotations(annotations map[string]string, timeout time.Duration) error {
+	if err := service.patchMetadata("annotations", annotations, timeout); err != nil {
+		return fmt.Errorf("failed to set service instance annotations: %w", err)
+	}
+	return nil
+}
+
+// Metadata fetches the metadata of the service instance.
+func (service *Service) Metadata(timeout time.Duration) (*Metadata, error) {
+	var instance struct {
+		Metadata Metadata `json:"metadata"`
+	}
+	if err := cfCurl(service.stderr, timeout, &instance, "/v3/service_instances/"+service.guid); err != nil {
+		return nil, fmt.Errorf("failed to get service instance metadata: %w", err)
+	}
+	return &instance.Metadata, nil
+}
+
+// patchMetadata updates the metadata field, either labels or annotations, of the service
+// instance. Empty values are sent as null, which removes them.
+func (service *Service) patchMetadata(field string, metadata map[string]string, timeout time.Duration) error {
+	values := make(map[string]interface{}, len(metadata))
+	for key, value := range metadata {
+		if value == "" {
+			values[key] = nil
+		} else {
+			values[key] = value
+		}
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{field: values},
+	})
+	if err != nil {
+		return err
+	}
+	var instance map[string]interface{}
+	return cfCurl(service.stderr, timeout, &instance, "-X", "PATCH", "-d", string(body), "/v3/service_instances/"+service.guid)
 }
 
-// Bind binds the service instance to an app.
-func (service *Service) Bind(appName string, timeout time.Duration) error {
-	session := cf.Cf("bind-service", appName, service.name).Wait(timeout)
+// Share shares the service instance with a space in another or the same organization.
+func (service *Service) Share(orgName string, spaceName string, timeout time.Duration) error {
+	session := cf.Cf("share-service", service.name, "-o", orgName, "-s", spaceName).Wait(timeout)
 	if exitCode := session.ExitCode(); exitCode != 0 {
-		return fmt.Errorf("failed to bind service instance: cf bind-service %s %s exited with code %d", appName, service.name, exitCode)
+		return fmt.Errorf(
+			"failed to share service instance: cf share-service %s exited with code %d: %s",
+			service.name, exitCode, strings.TrimSpace(string(session.Err.Contents())),
+		)
+	}
+	return nil
+}
+
+// Unshare unshares the service instance from a space, deleting the bindings in that space.
+func (service *Service) Unshare(orgName string, spaceName string, timeout time.Duration) error {
+	session := cf.Cf("unshare-service", service.name, "-o", orgName, "-s", spaceName, "-f").Wait(timeout)
+	if exitCode := session.ExitCode(); exitCode != 0 {
+		return fmt.Errorf("failed to unshare service instance: cf unshare-service %s exited with code %d", service.name, exitCode)
 	}
 	return nil
 }
@@ -217,6 +364,26 @@ func (service *Service) Unbind(appName string, timeout time.Duration) error {
 	return nil
 }
 
+// Delete deletes the service instance, along with its service key, and waits for the deletion to
+// complete.
+func (service *Service) Delete(timeout time.Duration) error {
+	if service.credentials != nil {
+		session := cf.Cf("delete-service-key", service.name, serviceKey, "-f").Wait(timeout)
+		if exitCode := session.ExitCode(); exitCode != 0 {
+			return fmt.Errorf("failed to delete service instance: cf delete-service-key %s %s exited with code %d", service.name, serviceKey, exitCode)
+		}
+		service.credentials = nil
+	}
+	session := cf.Cf("delete-service", service.name, "-f").Wait(timeout)
+	if exitCode := session.ExitCode(); exitCode != 0 {
+		return fmt.Errorf("failed to delete service instance: cf delete-service %s exited with code %d", service.name, exitCode)
+	}
+	if err := service.WaitForDelete(timeout); err != nil {
+		return fmt.Errorf("failed to delete service instance: %w", err)
+	}
+	return nil
+}
+
 // Destroy destroys all the created resources linked to the service instance.
 func (service *Service) Destroy(timeout time.Duration) {
 	cf.Cf("delete-service-key", service.name, serviceKey, "-f").Wait(timeout)
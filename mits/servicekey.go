@@ -0,0 +1,95 @@
+/*
+   Copyright 2020 SUSE
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package mits
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
+)
+
+// ServiceKey is a service key of a service instance.
+type ServiceKey struct {
+	GUID string
+	Name string
+}
+
+// CreateKey creates a service key with the given name and optional parameters.
+func (service *Service) CreateKey(name string, params map[string]interface{}, timeout time.Duration) (*ServiceKey, error) {
+	createArgs := []string{"create-service-key", service.name, name}
+	if params != nil {
+		paramsBytes, err := json.Marshal(params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create service key: %w", err)
+		}
+		createArgs = append(createArgs, "-c", string(paramsBytes))
+	}
+	session := cf.Cf(createArgs...).Wait(timeout)
+	if exitCode := session.ExitCode(); exitCode != 0 {
+		return nil, fmt.Errorf("failed to create service key: cf create-service-key %s %s exited with code %d", service.name, name, exitCode)
+	}
+	guid, err := cfGUID(service.stderr, timeout, "service-key", "--guid", service.name, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create service key: %w", err)
+	}
+	return &ServiceKey{GUID: guid, Name: name}, nil
+}
+
+// Keys lists the service keys of the service instance.
+func (service *Service) Keys(timeout time.Duration) ([]ServiceKey, error) {
+	query := url.Values{}
+	query.Set("type", "key")
+	query.Set("service_instance_guids", service.guid)
+	query.Set("per_page", "5000")
+	var body struct {
+		Resources []struct {
+			GUID string `json:"guid"`
+			Name string `json:"name"`
+		} `json:"resources"`
+	}
+	if err := cfCurl(service.stderr, timeout, &body, "/v3/service_credential_bindings?"+query.Encode()); err != nil {
+		return nil, fmt.Errorf("failed to list service keys: %w", err)
+	}
+	keys := make([]ServiceKey, 0, len(body.Resources))
+	for _, resource := range body.Resources {
+		keys = append(keys, ServiceKey{GUID: resource.GUID, Name: resource.Name})
+	}
+	return keys, nil
+}
+
+// KeyCredentials fetches the credentials of a service key.
+func (service *Service) KeyCredentials(key *ServiceKey, timeout time.Duration) (map[string]interface{}, error) {
+	var details struct {
+		Credentials map[string]interface{} `json:"credentials"`
+	}
+	if err := cfCurl(service.stderr, timeout, &details, "/v3/service_credential_bindings/"+key.GUID+"/details"); err != nil {
+		return nil, fmt.Errorf("failed to get service key credentials: %w", err)
+	}
+	return details.Credentials, nil
+}
+
+// DeleteKey deletes a service key.
+func (service *Service) DeleteKey(key *ServiceKey, timeout time.Duration) error {
+	session := cf.Cf("delete-service-key", service.name, key.Name, "-f").Wait(timeout)
+	if exitCode := session.ExitCode(); exitCode != 0 {
+		return fmt.Errorf("failed to delete service key: cf delete-service-key %s %s exited with code %d", service.name, key.Name, exitCode)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+/*
+   Copyright 2020 SUSE
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package mits
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gexec"
+
+	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/generator"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/workflowhelpers"
+
+	"github.com/SUSE/minibroker-integration-tests/mits/config"
+)
+
+// ServiceKeysLifecycle asserts that service keys can be created, listed, read and deleted. An asset
+// app is started with the credentials of a key instead of a binding to assert that they work
+// against the live service. Whether two keys share a password and whether the app can still restart
+// once its key is deleted are reported, and asserted when the testConfig expects per-binding
+// credentials.
+func ServiceKeysLifecycle(
+	testSetup *workflowhelpers.ReproducibleTestSuiteSetup,
+	mitsConfig *config.Config,
+	serviceBrokerName string,
+	c Case,
+) {
+	timeouts := mitsConfig.Timeouts.Merge(c.TestConfig.Timeouts)
+
+	params := config.MergeParams(c.Params, c.TestConfig.Params)
+	service, cleanup := provisionAndBind(testSetup, mitsConfig, serviceBrokerName, c.TestConfig, params, c.Asset, pushAsset)
+	defer cleanup()
+	serviceName := service.name
+
+	By("creating two service keys")
+	keys := make([]*ServiceKey, 2)
+	credentials := make([]map[string]interface{}, len(keys))
+	for i := range keys {
+		key, err := service.CreateKey(generator.PrefixedRandomName(c.TestConfig.Class, "key"), nil, testSetup.ShortTimeout())
+		Expect(err).NotTo(HaveOccurred())
+		defer service.DeleteKey(key, testSetup.ShortTimeout())
+		keys[i] = key
+		credentials[i], err = service.KeyCredentials(key, testSetup.ShortTimeout())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(credentials[i]).NotTo(BeEmpty())
+	}
+
+	By("listing the service keys")
+	listed, err := service.Keys(testSetup.ShortTimeout())
+	Expect(err).NotTo(HaveOccurred())
+	Expect(listed).To(ContainElements(*keys[0], *keys[1]))
+
+	By("comparing the passwords of the service keys")
+	firstPassword := credentialsPassword(credentials[0])
+	Expect(firstPassword).NotTo(BeEmpty())
+	sharedPassword := firstPassword == credentialsPassword(credentials[1])
+	if sharedPassword {
+		fmt.Fprintf(GinkgoWriter, "The service keys of %s share the same password\n", serviceName)
+	} else {
+		fmt.Fprintf(GinkgoWriter, "The service keys of %s have distinct passwords\n", serviceName)
+	}
+	if c.TestConfig.PerBindingCredentials {
+		Expect(sharedPassword).To(BeFalse(), "the service keys share the same password")
+	}
+
+	defer setupSecurityGroup(testSetup, mitsConfig.SecurityGroups, c.TestConfig, service)()
+
+	appName := generator.PrefixedRandomName(c.TestConfig.Class, "app")
+	defer pushAsset(testSetup, timeouts, appName, serviceName, c.Asset)()
+
+	By("setting the SERVICE_CREDENTIALS environment variable in the app to the first key")
+	credentialsBytes, err := json.Marshal(credentials[0])
+	Expect(err).NotTo(HaveOccurred())
+	Expect(
+		cf.Cf("set-env", appName, "SERVICE_CREDENTIALS", string(credentialsBytes)).
+			Wait(testSetup.ShortTimeout()),
+	).To(Exit(0))
+
+	defer func() {
+		cf.Cf("logs", appName, "--recent").Wait(testSetup.ShortTimeout())
+	}()
+	By("starting the app with the service key credentials")
+	Expect(
+		cf.Cf("start", appName).
+			Wait(timeouts.CFStart),
+	).To(Exit(0))
+
+	By("deleting the first service key")
+	err = service.DeleteKey(keys[0], testSetup.ShortTimeout())
+	Expect(err).NotTo(HaveOccurred())
+	listed, err = service.Keys(testSetup.ShortTimeout())
+	Expect(err).NotTo(HaveOccurred())
+	Expect(listed).NotTo(ContainElement(*keys[0]))
+	Expect(listed).To(ContainElement(*keys[1]))
+
+	By("restarting the app with the deleted service key credentials")
+	revoked := cf.Cf("restart", appName).Wait(timeouts.CFStart).ExitCode() != 0
+	if revoked {
+		fmt.Fprintf(GinkgoWriter, "Deleting the service key of %s revoked its credentials\n", serviceName)
+	} else {
+		fmt.Fprintf(GinkgoWriter, "Deleting the service key of %s is a no-op: its credentials still work\n", serviceName)
+	}
+	if c.TestConfig.PerBindingCredentials {
+		Expect(revoked).To(BeTrue(), "the deleted service key credentials still work")
+	}
+}
+
+// credentialsPassword returns the password from the service credentials, either set explicitly or
+// as part of the URI.
+func credentialsPassword(credentials map[string]interface{}) string {
+	if password, ok := credentials["password"].(string); ok {
+		return password
+	}
+	if uri, ok := credentials["uri"].(string); ok {
+		if parsed, err := url.Parse(uri); err == nil && parsed.User != nil {
+			password, _ := parsed.User.Password()
+			return password
+		}
+	}
+	return ""
+}
@@ -0,0 +1,148 @@
+/*
+   Copyright 2020 SUSE
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package mits
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gexec"
+
+	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/generator"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/workflowhelpers"
+
+	"github.com/SUSE/minibroker-integration-tests/mits/config"
+)
+
+// ServiceSharing asserts that a service instance can be shared with a second space in the test
+// organization, where an app bound to it must start, when the service offering is shareable.
+// Otherwise, sharing the service instance must be rejected for that reason. Creating the second
+// space requires the admin user, and the spec is skipped when the service_instance_sharing feature
+// flag is disabled, since CF rejects any sharing then.
+func ServiceSharing(
+	testSetup *workflowhelpers.ReproducibleTestSuiteSetup,
+	mitsConfig *config.Config,
+	serviceBrokerName string,
+	c Case,
+) {
+	timeouts := mitsConfig.Timeouts.Merge(c.TestConfig.Timeouts)
+	orgName := testSetup.TestSpace.OrganizationName()
+
+	By("checking the service_instance_sharing feature flag")
+	var sharingEnabled bool
+	workflowhelpers.AsUser(testSetup.AdminUserContext(), testSetup.ShortTimeout(), func() {
+		var err error
+		sharingEnabled, err = featureFlagEnabled("service_instance_sharing", GinkgoWriter, testSetup.ShortTimeout())
+		Expect(err).NotTo(HaveOccurred())
+	})
+	if !sharingEnabled {
+		Skip("the service_instance_sharing feature flag is disabled, enable it with: cf enable-feature-flag service_instance_sharing")
+	}
+
+	By("fetching the plan from the catalog")
+	plan, err := FetchPlan(serviceBrokerName, c.TestConfig.Class, c.TestConfig.Plan, GinkgoWriter, testSetup.ShortTimeout())
+	Expect(err).NotTo(HaveOccurred())
+
+	params := config.MergeParams(c.Params, c.TestConfig.Params)
+	service, cleanup := provisionAndBind(testSetup, mitsConfig, serviceBrokerName, c.TestConfig, params, c.Asset, pushAsset)
+	defer cleanup()
+
+	spaceName := generator.PrefixedRandomName("mits", "shared-space")
+	defer createSpace(testSetup, spaceName)()
+
+	if !plan.Offering.Shareable {
+		By("sharing the service instance the catalog declares not shareable")
+		err = service.Share(orgName, spaceName, testSetup.ShortTimeout())
+		Expect(err).To(HaveOccurred(), "the %s service offering isn't shareable but sharing was accepted", c.TestConfig.Class)
+		Expect(err).To(MatchError(ContainSubstring("does not support service instance sharing")))
+		return
+	}
+
+	By("sharing the service instance with the second space")
+	err = service.Share(orgName, spaceName, testSetup.ShortTimeout())
+	Expect(err).NotTo(HaveOccurred())
+	defer service.Unshare(orgName, spaceName, testSetup.ShortTimeout())
+
+	defer setupSpaceSecurityGroup(testSetup, mitsConfig.SecurityGroups, c.TestConfig, service, spaceName)()
+
+	By("targeting the second space")
+	Expect(
+		cf.Cf("target", "-o", orgName, "-s", spaceName).
+			Wait(testSetup.ShortTimeout()),
+	).To(Exit(0))
+	defer func() {
+		cf.Cf("target", "-o", orgName, "-s", testSetup.TestSpace.SpaceName()).Wait(testSetup.ShortTimeout())
+	}()
+
+	appName := generator.PrefixedRandomName(c.TestConfig.Class, "app")
+	defer pushAsset(testSetup, timeouts, appName, service.name, c.Asset)()
+
+	By("binding the shared service instance to the app in the second space")
+	err = service.Bind(appName, "", c.TestConfig.BindParams, testSetup.ShortTimeout())
+	Expect(err).NotTo(HaveOccurred())
+	defer service.Unbind(appName, testSetup.ShortTimeout())
+
+	defer func() {
+		cf.Cf("logs", appName, "--recent").Wait(testSetup.ShortTimeout())
+	}()
+	By("starting the app in the second space")
+	Expect(
+		cf.Cf("start", appName).
+			Wait(timeouts.CFStart),
+	).To(Exit(0))
+}
+
+// createSpace creates a space in the test organization, in which the regular user is a space
+// developer. It returns a function that deletes the space.
+func createSpace(testSetup *workflowhelpers.ReproducibleTestSuiteSetup, spaceName string) func() {
+	orgName := testSetup.TestSpace.OrganizationName()
+	By("creating a second space")
+	workflowhelpers.AsUser(testSetup.AdminUserContext(), testSetup.ShortTimeout(), func() {
+		Expect(
+			cf.Cf("create-space", spaceName, "-o", orgName).
+				Wait(testSetup.ShortTimeout()),
+		).To(Exit(0))
+	})
+	deleteSpace := func() {
+		workflowhelpers.AsUser(testSetup.AdminUserContext(), testSetup.ShortTimeout(), func() {
+			cf.Cf("delete-space", spaceName, "-o", orgName, "-f").Wait(testSetup.LongTimeout())
+		})
+	}
+	defer cleanupOnFailure(deleteSpace)
+	workflowhelpers.AsUser(testSetup.AdminUserContext(), testSetup.ShortTimeout(), func() {
+		Expect(
+			cf.Cf("set-space-role", testSetup.RegularUserContext().Username, orgName, spaceName, "SpaceDeveloper").
+				Wait(testSetup.ShortTimeout()),
+		).To(Exit(0))
+	})
+	return deleteSpace
+}
+
+// featureFlagEnabled returns whether the CF feature flag is enabled.
+func featureFlagEnabled(name string, stderr io.Writer, timeout time.Duration) (bool, error) {
+	var flag struct {
+		Enabled bool `json:"enabled"`
+	}
+	if err := cfCurl(stderr, timeout, &flag, "/v3/feature_flags/"+name); err != nil {
+		return false, fmt.Errorf("failed to get feature flag %s: %w", name, err)
+	}
+	return flag.Enabled, nil
+}
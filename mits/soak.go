@@ -0,0 +1,100 @@
+/*
+   Copyright 2020 SUSE
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package mits
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/SUSE/minibroker-integration-tests/mits/config"
+	"github.com/SUSE/minibroker-integration-tests/mits/soak"
+)
+
+// SoakController periodically exercises the workload of a running asset app through its
+// /workload endpoint.
+type SoakController struct {
+	url    string
+	config config.Soak
+	client *http.Client
+	stdout io.Writer
+}
+
+// NewSoakController instantiates a new SoakController for the app served at appURL. The soak
+// duration and interval must be positive.
+func NewSoakController(appURL string, soakConfig config.Soak, stdout io.Writer) (*SoakController, error) {
+	if soakConfig.Duration <= 0 || soakConfig.Interval <= 0 {
+		return nil, fmt.Errorf("failed to create soak controller: the duration and interval must be positive")
+	}
+	return &SoakController{
+		url:    appURL + "/workload",
+		config: soakConfig,
+		client: newAppClient(soakConfig.Interval),
+		stdout: stdout,
+	}, nil
+}
+
+// Run probes the app on every interval until the soak duration elapses.
+func (controller *SoakController) Run() soak.Report {
+	var report soak.Report
+	deadline := time.Now().Add(controller.config.Duration)
+	ticker := time.NewTicker(controller.config.Interval)
+	defer ticker.Stop()
+	for time.Now().Before(deadline) {
+		<-ticker.C
+
+		start := time.Now()
+		workload, err := probeWorkload(controller.client, controller.url)
+		latency := time.Since(start)
+		if err != nil {
+			fmt.Fprintf(controller.stdout, "soak probe to %s failed after %s: %v\n", controller.url, latency, err)
+		}
+		report.Record(start, latency, workload, err)
+	}
+	return report
+}
+
+// newAppClient returns an HTTP client for reaching the asset apps.
+func newAppClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// The suite runs with SSL validation skipped, so do the apps.
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+}
+
+// probeWorkload runs the workload of an asset app once through its /workload endpoint at url.
+func probeWorkload(client *http.Client, url string) (soak.Workload, error) {
+	var workload soak.Workload
+	res, err := client.Get(url)
+	if err != nil {
+		return workload, err
+	}
+	defer res.Body.Close()
+	if err := json.NewDecoder(res.Body).Decode(&workload); err != nil {
+		return workload, fmt.Errorf("unexpected response with status %q: %w", res.Status, err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return workload, fmt.Errorf("workload failed with status %q: %s", res.Status, workload.Error)
+	}
+	return workload, nil
+}
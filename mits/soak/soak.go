@@ -0,0 +1,97 @@
+/*
+   Copyright 2020 SUSE
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package soak accounts for the probes performed against the asset apps during a soak.
+package soak
+
+import (
+	"fmt"
+	"time"
+)
+
+// Workload mirrors the body served by the asset apps on /workload.
+type Workload struct {
+	Error      string    `json:"error"`
+	Reconnects int       `json:"reconnects"`
+	StartedAt  time.Time `json:"started_at"`
+}
+
+// Report aggregates the probes performed during a soak.
+type Report struct {
+	Probes       int
+	Failures     int
+	Reconnects   int
+	Restarts     int
+	TotalLatency time.Duration
+	MaxLatency   time.Duration
+	Errors       []string
+
+	// last is the workload reported by the last successful probe.
+	last Workload
+}
+
+// Record accounts for a probe started at start that took latency. The workload is only used when
+// the probe succeeded. A change of the app start time means the app restarted, so its reconnects
+// counter started over.
+func (report *Report) Record(start time.Time, latency time.Duration, workload Workload, err error) {
+	report.Probes++
+	report.TotalLatency += latency
+	if latency > report.MaxLatency {
+		report.MaxLatency = latency
+	}
+	if err != nil {
+		report.Failures++
+		report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", start.Format(time.RFC3339), err))
+		return
+	}
+
+	if !report.last.StartedAt.IsZero() && !workload.StartedAt.Equal(report.last.StartedAt) {
+		report.Restarts++
+		report.Reconnects += workload.Reconnects
+	} else {
+		report.Reconnects += workload.Reconnects - report.last.Reconnects
+	}
+	report.last = workload
+}
+
+// Availability returns the ratio of successful probes.
+func (report Report) Availability() float64 {
+	if report.Probes == 0 {
+		return 0
+	}
+	return float64(report.Probes-report.Failures) / float64(report.Probes)
+}
+
+// MeanLatency returns the mean latency of all the probes.
+func (report Report) MeanLatency() time.Duration {
+	if report.Probes == 0 {
+		return 0
+	}
+	return report.TotalLatency / time.Duration(report.Probes)
+}
+
+func (report Report) String() string {
+	return fmt.Sprintf(
+		"probes: %d, failures: %d, availability: %.4f, mean latency: %s, max latency: %s, reconnects: %d, restarts: %d",
+		report.Probes,
+		report.Failures,
+		report.Availability(),
+		report.MeanLatency(),
+		report.MaxLatency,
+		report.Reconnects,
+		report.Restarts,
+	)
+}
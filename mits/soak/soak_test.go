@@ -0,0 +1,102 @@
+/*
+   Copyright 2020 SUSE
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package soak_test
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/SUSE/minibroker-integration-tests/mits/soak"
+)
+
+var _ = Describe("Report", func() {
+	start := time.Date(2020, 7, 1, 12, 0, 0, 0, time.UTC)
+	restarted := start.Add(time.Hour)
+
+	DescribeTable("Availability",
+		func(probes int, failures int, expected float64) {
+			report := soak.Report{Probes: probes, Failures: failures}
+			Expect(report.Availability()).To(BeNumerically("~", expected, 1e-9))
+		},
+		Entry("without probes", 0, 0, 0.0),
+		Entry("without failures", 10, 0, 1.0),
+		Entry("with some failures", 4, 1, 0.75),
+		Entry("with only failures", 3, 3, 0.0),
+	)
+
+	type probe struct {
+		workload soak.Workload
+		err      error
+	}
+
+	DescribeTable("Record",
+		func(probes []probe, expected soak.Report) {
+			var report soak.Report
+			for _, p := range probes {
+				report.Record(start, time.Second, p.workload, p.err)
+			}
+			Expect(report.Probes).To(Equal(expected.Probes))
+			Expect(report.Failures).To(Equal(expected.Failures))
+			Expect(report.Reconnects).To(Equal(expected.Reconnects))
+			Expect(report.Restarts).To(Equal(expected.Restarts))
+			Expect(report.Errors).To(HaveLen(expected.Failures))
+		},
+		Entry("a steady app",
+			[]probe{
+				{workload: soak.Workload{StartedAt: start}},
+				{workload: soak.Workload{StartedAt: start}},
+			},
+			soak.Report{Probes: 2},
+		),
+		Entry("an app reconnecting",
+			[]probe{
+				{workload: soak.Workload{StartedAt: start}},
+				{workload: soak.Workload{StartedAt: start, Reconnects: 2}},
+				{workload: soak.Workload{StartedAt: start, Reconnects: 3}},
+			},
+			soak.Report{Probes: 3, Reconnects: 3},
+		),
+		Entry("an app restarting, which resets its reconnects counter",
+			[]probe{
+				{workload: soak.Workload{StartedAt: start, Reconnects: 2}},
+				{workload: soak.Workload{StartedAt: restarted, Reconnects: 1}},
+				{workload: soak.Workload{StartedAt: restarted, Reconnects: 1}},
+			},
+			soak.Report{Probes: 3, Reconnects: 3, Restarts: 1},
+		),
+		Entry("failed probes, which don't count as restarts",
+			[]probe{
+				{workload: soak.Workload{StartedAt: start}},
+				{err: fmt.Errorf("connection refused")},
+				{workload: soak.Workload{StartedAt: start, Reconnects: 1}},
+			},
+			soak.Report{Probes: 3, Failures: 1, Reconnects: 1},
+		),
+	)
+
+	It("should track the mean and max latencies", func() {
+		var report soak.Report
+		report.Record(start, time.Second, soak.Workload{StartedAt: start}, nil)
+		report.Record(start, 3*time.Second, soak.Workload{StartedAt: start}, nil)
+		Expect(report.MeanLatency()).To(Equal(2 * time.Second))
+		Expect(report.MaxLatency).To(Equal(3 * time.Second))
+	})
+})
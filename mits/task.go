@@ -0,0 +1,117 @@
+/*
+   Copyright 2020 SUSE
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package mits
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/onsi/gomega/gexec"
+
+	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
+)
+
+// The terminal states of a CF task.
+const (
+	TaskSucceeded = "SUCCEEDED"
+	TaskFailed    = "FAILED"
+)
+
+// Task is a one-off process run from the droplet of an app.
+type Task struct {
+	GUID   string `json:"guid"`
+	Name   string `json:"name"`
+	State  string `json:"state"`
+	Result struct {
+		FailureReason string `json:"failure_reason"`
+	} `json:"result"`
+}
+
+// RunTask runs the command as a task of the app with cf run-task. The task name must be unique for
+// the app, since it is used to look the task up once created.
+func RunTask(appName string, taskName string, command string, stderr io.Writer, timeout time.Duration) (*Task, error) {
+	session := cf.Cf("run-task", appName, "--command", command, "--name", taskName).Wait(timeout)
+	if exitCode := session.ExitCode(); exitCode != 0 {
+		return nil, fmt.Errorf("failed to run task: cf run-task exited with code %d", exitCode)
+	}
+
+	appGUID, err := cfGUID(stderr, timeout, "app", "--guid", appName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run task: %w", err)
+	}
+	query := url.Values{}
+	query.Set("names", taskName)
+	var tasks struct {
+		Resources []Task `json:"resources"`
+	}
+	if err := cfCurl(stderr, timeout, &tasks, "/v3/apps/"+appGUID+"/tasks?"+query.Encode()); err != nil {
+		return nil, fmt.Errorf("failed to run task: %w", err)
+	}
+	if len(tasks.Resources) != 1 {
+		return nil, fmt.Errorf("failed to run task: found %d tasks named %s", len(tasks.Resources), taskName)
+	}
+	return &tasks.Resources[0], nil
+}
+
+// WaitForTask polls the task until it either succeeds or fails, and returns its final state. A
+// failed task is returned along with an error holding its failure reason.
+func WaitForTask(task *Task, stderr io.Writer, timeout time.Duration) (*Task, error) {
+	timeLimit := time.Now().Add(timeout)
+	for {
+		if time.Now().After(timeLimit) {
+			return nil, fmt.Errorf("failed to wait for task %s: timed out", task.Name)
+		}
+
+		var current Task
+		if err := cfCurl(stderr, timeout, &current, "/v3/tasks/"+task.GUID); err != nil {
+			return nil, fmt.Errorf("failed to wait for task %s: %w", task.Name, err)
+		}
+		switch current.State {
+		case TaskSucceeded:
+			return &current, nil
+		case TaskFailed:
+			return &current, fmt.Errorf("task %s failed: %s", task.Name, current.Result.FailureReason)
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
+
+// TaskLogs returns the recent logs of the app emitted by the task, i.e. with the APP/TASK/<name>
+// source.
+func TaskLogs(appName string, taskName string, stderr io.Writer, timeout time.Duration) (string, error) {
+	var logsBuilder strings.Builder
+	session, err := gexec.Start(exec.Command("cf", "logs", appName, "--recent"), &logsBuilder, stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to get task logs: %w", err)
+	}
+	if exitCode := session.Wait(timeout).ExitCode(); exitCode != 0 {
+		return "", fmt.Errorf("failed to get task logs: cf logs exited with code %d", exitCode)
+	}
+
+	source := "[APP/TASK/" + taskName + "/"
+	var lines []string
+	for _, line := range strings.Split(logsBuilder.String(), "\n") {
+		if strings.Contains(line, source) {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
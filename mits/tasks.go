@@ -0,0 +1,109 @@
+/*
+   Copyright 2020 SUSE
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package mits
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gexec"
+
+	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/generator"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/workflowhelpers"
+
+	"github.com/SUSE/minibroker-integration-tests/mits/config"
+)
+
+// TaskChecks asserts the asset app checks pass when run as CF tasks instead of a long-running app.
+// The app is staged once without any web instance and bound to serviceCount service instances,
+// then a task runs the checks against each binding in CHECK_ONLY mode, all at once. The output of a
+// failed task is attached to the failure.
+func TaskChecks(
+	testSetup *workflowhelpers.ReproducibleTestSuiteSetup,
+	mitsConfig *config.Config,
+	serviceBrokerName string,
+	c Case,
+	serviceCount int,
+) {
+	timeouts := mitsConfig.Timeouts.Merge(c.TestConfig.Timeouts)
+	appName := generator.PrefixedRandomName(c.TestConfig.Class, "app")
+	params := config.MergeParams(c.Params, c.TestConfig.Params)
+	validateProvisioningParams(testSetup, serviceBrokerName, c.TestConfig, params)
+
+	services := make([]*Service, serviceCount)
+	for i := range services {
+		serviceName := generator.PrefixedRandomName(c.TestConfig.Class, "service")
+		services[i] = NewService(serviceName, serviceBrokerName, GinkgoWriter, GinkgoWriter)
+
+		By(fmt.Sprintf("creating the service instance %d", i+1))
+		err := services[i].Create(c.TestConfig, params, timeouts.CFCreateService)
+		Expect(err).NotTo(HaveOccurred())
+		defer services[i].Destroy(testSetup.ShortTimeout())
+	}
+
+	defer pushAsset(testSetup, timeouts, appName, services[0].name, c.Asset)()
+
+	for i, service := range services {
+		By(fmt.Sprintf("waiting for the service instance %d to become ready", i+1))
+		err := service.WaitForCreate(timeouts.CFCreateService)
+		Expect(err).NotTo(HaveOccurred())
+
+		By(fmt.Sprintf("binding the service instance %d to the app", i+1))
+		err = service.Bind(appName, "", c.TestConfig.BindParams, testSetup.ShortTimeout())
+		Expect(err).NotTo(HaveOccurred())
+		defer service.Unbind(appName, testSetup.ShortTimeout())
+
+		defer setupSecurityGroup(testSetup, mitsConfig.SecurityGroups, c.TestConfig, service)()
+	}
+
+	defer func() {
+		cf.Cf("logs", appName, "--recent").Wait(testSetup.ShortTimeout())
+	}()
+	By("staging the app without any web instance")
+	Expect(
+		cf.Cf("scale", appName, "-i", "0").
+			Wait(testSetup.ShortTimeout()),
+	).To(Exit(0))
+	Expect(
+		cf.Cf("start", appName).
+			Wait(timeouts.CFStart),
+	).To(Exit(0))
+
+	tasks := make([]*Task, len(services))
+	for i, service := range services {
+		By(fmt.Sprintf("running the checks against the service instance %d as a task", i+1))
+		command := fmt.Sprintf("CHECK_ONLY=true SERVICE_NAME=%s ./bin/%s", service.name, c.Asset)
+		task, err := RunTask(appName, fmt.Sprintf("checks-%d", i+1), command, GinkgoWriter, testSetup.ShortTimeout())
+		Expect(err).NotTo(HaveOccurred())
+		tasks[i] = task
+	}
+
+	for i, task := range tasks {
+		By(fmt.Sprintf("waiting for the task against the service instance %d to finish", i+1))
+		task, err := WaitForTask(task, GinkgoWriter, timeouts.CFStart)
+		if task != nil && task.State == TaskFailed {
+			output, logsErr := TaskLogs(appName, task.Name, GinkgoWriter, testSetup.ShortTimeout())
+			if logsErr != nil {
+				output = logsErr.Error()
+			}
+			Expect(err).NotTo(HaveOccurred(), "task %s output:\n%s", task.Name, output)
+		}
+		Expect(err).NotTo(HaveOccurred())
+	}
+}
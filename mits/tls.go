@@ -0,0 +1,59 @@
+/*
+   Copyright 2020 SUSE
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package mits
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gexec"
+
+	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/generator"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/workflowhelpers"
+
+	"github.com/SUSE/minibroker-integration-tests/mits/config"
+)
+
+// TLSAppAndService asserts that an app can connect over TLS to a service provisioned with the
+// TLSParams from the test config merged over the case params. The app is started with SERVICE_TLS
+// set, so it must connect over TLS even if the credentials don't say so, and reports the negotiated
+// protocol and whether the server certificate was verified.
+func TLSAppAndService(
+	testSetup *workflowhelpers.ReproducibleTestSuiteSetup,
+	mitsConfig *config.Config,
+	serviceBrokerName string,
+	c Case,
+) {
+	timeouts := mitsConfig.Timeouts.Merge(c.TestConfig.Timeouts)
+	appName := generator.PrefixedRandomName(c.TestConfig.Class, "app")
+
+	params := config.MergeParams(config.MergeParams(c.Params, c.TestConfig.Params), c.TestConfig.TLSParams)
+	_, cleanup := provisionAndBind(testSetup, mitsConfig, serviceBrokerName, c.TestConfig, params, c.Asset, pushAsset, appName)
+	defer cleanup()
+
+	By("setting the SERVICE_TLS environment variable in the app")
+	Expect(
+		cf.Cf("set-env", appName, "SERVICE_TLS", "true").
+			Wait(testSetup.ShortTimeout()),
+	).To(Exit(0))
+
+	By("starting the app connecting over TLS")
+	Expect(
+		cf.Cf("start", appName).
+			Wait(timeouts.CFStart),
+	).To(Exit(0))
+}
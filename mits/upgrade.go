@@ -0,0 +1,157 @@
+/*
+   Copyright 2020 SUSE
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package mits
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gexec"
+
+	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/generator"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/workflowhelpers"
+
+	"github.com/SUSE/minibroker-integration-tests/mits/config"
+)
+
+// UpgradeCompatibility asserts that the service instances provisioned and seeded before a
+// Minibroker upgrade can still be bound, unbound, read from and deprovisioned after it. The
+// scenario pauses while the upgrade is performed as configured by mitsConfig.Upgrade.
+func UpgradeCompatibility(
+	testSetup *workflowhelpers.ReproducibleTestSuiteSetup,
+	mitsConfig *config.Config,
+	serviceBrokerName string,
+	cases []Case,
+) {
+	seedValue := generator.PrefixedRandomName("mits", "seed")
+
+	services := make([]*Service, len(cases))
+	for i, c := range cases {
+		serviceName := generator.PrefixedRandomName(c.TestConfig.Class, "service")
+		service := NewService(serviceName, serviceBrokerName, GinkgoWriter, GinkgoWriter)
+		timeouts := mitsConfig.Timeouts.Merge(c.TestConfig.Timeouts)
+
+		params := config.MergeParams(c.Params, c.TestConfig.Params)
+		validateProvisioningParams(testSetup, serviceBrokerName, c.TestConfig, params)
+
+		By(fmt.Sprintf("creating the %s service instance", c.TestConfig.Class))
+		err := service.Create(c.TestConfig, params, timeouts.CFCreateService)
+		Expect(err).NotTo(HaveOccurred())
+		defer service.Destroy(testSetup.ShortTimeout())
+		services[i] = service
+	}
+
+	for i, c := range cases {
+		timeouts := mitsConfig.Timeouts.Merge(c.TestConfig.Timeouts)
+
+		By(fmt.Sprintf("waiting for the %s service instance to become ready", c.TestConfig.Class))
+		err := services[i].WaitForCreate(timeouts.CFCreateService)
+		Expect(err).NotTo(HaveOccurred())
+
+		defer setupSecurityGroup(testSetup, mitsConfig.SecurityGroups, c.TestConfig, services[i])()
+
+		By(fmt.Sprintf("seeding the %s service instance", c.TestConfig.Class))
+		runSeedApp(testSetup, timeouts, c, services[i], "write", seedValue)
+	}
+
+	By("waiting for Minibroker to be upgraded")
+	err := waitForUpgrade(mitsConfig.Upgrade)
+	Expect(err).NotTo(HaveOccurred())
+
+	for i, c := range cases {
+		timeouts := mitsConfig.Timeouts.Merge(c.TestConfig.Timeouts)
+
+		By(fmt.Sprintf("reading the seeded value from the %s service instance", c.TestConfig.Class))
+		runSeedApp(testSetup, timeouts, c, services[i], "read", seedValue)
+
+		By(fmt.Sprintf("deprovisioning the %s service instance", c.TestConfig.Class))
+		err := services[i].Delete(timeouts.CFCreateService)
+		Expect(err).NotTo(HaveOccurred())
+	}
+}
+
+// runSeedApp binds an asset app to the service instance and starts it with the given SEED_MODE,
+// asserting that the value is seeded or verified. The app is unbound and deleted afterwards.
+func runSeedApp(
+	testSetup *workflowhelpers.ReproducibleTestSuiteSetup,
+	timeouts config.Timeouts,
+	c Case,
+	service *Service,
+	mode string,
+	value string,
+) {
+	appName := generator.PrefixedRandomName(c.TestConfig.Class, "app")
+	defer pushAsset(testSetup, timeouts, appName, service.name, c.Asset)()
+
+	Expect(
+		cf.Cf("set-env", appName, "SEED_MODE", mode).
+			Wait(testSetup.ShortTimeout()),
+	).To(Exit(0))
+	Expect(
+		cf.Cf("set-env", appName, "SEED_VALUE", value).
+			Wait(testSetup.ShortTimeout()),
+	).To(Exit(0))
+
+	err := service.Bind(appName, "", c.TestConfig.BindParams, testSetup.ShortTimeout())
+	Expect(err).NotTo(HaveOccurred())
+
+	defer func() {
+		cf.Cf("logs", appName, "--recent").Wait(testSetup.ShortTimeout())
+	}()
+	Expect(
+		cf.Cf("start", appName).
+			Wait(timeouts.CFStart),
+	).To(Exit(0))
+
+	err = service.Unbind(appName, testSetup.ShortTimeout())
+	Expect(err).NotTo(HaveOccurred())
+}
+
+// waitForUpgrade runs the upgrade command if one is configured. Otherwise, it waits for the
+// signal file to be created.
+func waitForUpgrade(upgradeConfig config.Upgrade) error {
+	if upgradeConfig.Command != "" {
+		cmd := exec.Command("sh", "-c", upgradeConfig.Command)
+		session, err := Start(cmd, GinkgoWriter, GinkgoWriter)
+		if err != nil {
+			return fmt.Errorf("failed to run upgrade command: %w", err)
+		}
+		if exitCode := session.Wait(upgradeConfig.Timeout).ExitCode(); exitCode != 0 {
+			return fmt.Errorf("failed to run upgrade command: exited with code %d", exitCode)
+		}
+		return nil
+	}
+
+	fmt.Fprintf(GinkgoWriter, "Waiting for the signal file %s to be created...\n", upgradeConfig.SignalFile)
+	timeLimit := time.Now().Add(upgradeConfig.Timeout)
+	for {
+		if _, err := os.Stat(upgradeConfig.SignalFile); err == nil {
+			return nil
+		} else if !os.IsNotExist(err) {
+			return fmt.Errorf("failed to wait for signal file: %w", err)
+		}
+		if time.Now().After(timeLimit) {
+			return fmt.Errorf("failed to wait for signal file: timed out")
+		}
+		time.Sleep(time.Second)
+	}
+}
@@ -0,0 +1,42 @@
+/*
+   Copyright 2020 SUSE
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package mits_test
+
+import (
+	. "github.com/onsi/ginkgo"
+
+	"github.com/SUSE/minibroker-integration-tests/mits"
+)
+
+var _ = Describe("Minibroker upgrade", func() {
+	BeforeEach(func() {
+		if !mitsConfig.Upgrade.Enabled {
+			Skip("The upgrade scenario is disabled")
+		}
+	})
+
+	It("should keep the existing service instances working after the upgrade", func() {
+		var cases []mits.Case
+		for _, serviceTest := range serviceTests {
+			if serviceTest.config().Enabled {
+				cases = append(cases, serviceTest.mitsCase())
+			}
+		}
+
+		mits.UpgradeCompatibility(testSetup, mitsConfig, serviceBrokerName, cases)
+	})
+})